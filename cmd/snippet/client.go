@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// client calls the Snippetbox JSON API, see /api/openapi.json.
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// newClient returns a client for the configured server.  If the config
+// names a CA file, its certificates are trusted as well as the system's,
+// e.g. for a development server with a self-signed certificate.
+func newClient(cfg *config) (*client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.CA != "" {
+		pem, err := os.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", cfg.CA)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &client{
+		server: strings.TrimSuffix(cfg.Server, "/"),
+		token:  cfg.Token,
+		http:   &http.Client{Timeout: 30 * time.Second, Transport: transport},
+	}, nil
+}
+
+type snippet struct {
+	ID         int       `json:"id"`
+	Title      string    `json:"title"`
+	Content    string    `json:"content"`
+	Created    time.Time `json:"created"`
+	Expires    time.Time `json:"expires"`
+	Visibility string    `json:"visibility"`
+	Tags       []string  `json:"tags"`
+}
+
+type snippetInput struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Expires    int      `json:"expires,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+type snippetList struct {
+	Snippets []snippet `json:"snippets"`
+	Page     int       `json:"page"`
+	NextPage *int      `json:"next_page"`
+}
+
+// apiError is returned for error responses from the server.
+type apiError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "\n  %s: %s", k, e.Fields[k])
+	}
+
+	return b.String()
+}
+
+// do sends a request with an optional JSON body and decodes the JSON
+// response into dst, if it isn't nil.
+func (c *client) do(method, path string, body, dst any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, c.server+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var e struct {
+			Error apiError `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Error.Message == "" {
+			return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return &e.Error
+	}
+
+	if dst == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func (c *client) create(input snippetInput) (*snippet, error) {
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodPost, "/api/v1/snippets", input, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Snippet, nil
+}
+
+func (c *client) get(id int) (*snippet, error) {
+	var resp struct {
+		Snippet snippet `json:"snippet"`
+	}
+
+	err := c.do(http.MethodGet, fmt.Sprintf("/api/v1/snippets/%d", id), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Snippet, nil
+}
+
+func (c *client) list(query url.Values) (*snippetList, error) {
+	var resp snippetList
+
+	err := c.do(http.MethodGet, "/api/v1/snippets?"+query.Encode(), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// viewURL is the snippet's page on the website.
+func (c *client) viewURL(id int) string {
+	return fmt.Sprintf("%s/snippet/view/%d", c.server, id)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientCA(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	ca := filepath.Join(t.TempDir(), "cert.pem")
+	err := os.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ca      string
+		wantErr bool
+	}{
+		{name: "System roots", wantErr: true},
+		{name: "Server CA", ca: ca},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newClient(&config{Server: ts.URL, CA: tt.ca})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rs, err := c.http.Get(ts.URL)
+			if err == nil {
+				rs.Body.Close()
+			}
+
+			if tt.wantErr != (err != nil) {
+				t.Errorf("got error %v; want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSaveConfigMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("SNIPPET_CONFIG", path)
+
+	// left readable by an older version
+	err := os.WriteFile(path, []byte("{}\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = saveConfig(&config{Server: defaultServer, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("config file mode is %o; want 600", mode)
+	}
+}
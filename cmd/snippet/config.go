@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const defaultServer = "https://localhost:4000"
+
+// config is saved by "snippet login" and read by every other command.
+type config struct {
+	Server string `json:"server"`
+	Token  string `json:"token"`
+	CA     string `json:"ca,omitempty"` // PEM file of an extra root CA to trust
+}
+
+// configPath returns the config file location, which can be overridden with
+// the SNIPPET_CONFIG environment variable.
+func configPath() (string, error) {
+	if path := os.Getenv("SNIPPET_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "snippet", "config.json"), nil
+}
+
+func loadConfig() (*config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("not logged in, run \"snippet login\" first")
+		}
+		return nil, err
+	}
+
+	var cfg config
+	err = json.Unmarshal(b, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if cfg.Server == "" {
+		cfg.Server = defaultServer
+	}
+
+	return &cfg, nil
+}
+
+// saveConfig writes the config readable only by the current user, since it
+// holds an API token.
+func saveConfig(cfg *config) (string, error) {
+	path, err := configPath()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(path, append(b, '\n'), 0o600)
+	if err != nil {
+		return "", err
+	}
+
+	// WriteFile only sets the mode of new files
+	return path, os.Chmod(path, 0o600)
+}
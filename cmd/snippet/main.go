@@ -0,0 +1,339 @@
+// Command snippet creates and fetches snippets from the terminal using the
+// Snippetbox JSON API.
+//
+//	snippet login [-server URL] [-ca FILE]
+//	snippet push [-e 7d] [-t tag]... [-title T] [-private] [file...]
+//	snippet get ID
+//	snippet list [-tag T] [-expired] [-page N]
+//
+// Commands authenticate with a personal API token, created at
+// /account/tokens on the website and saved by "snippet login".
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// maxContentBytes stays under the server's limit on request bodies.
+const maxContentBytes = 1<<20 - 4096
+
+const usage = `usage: snippet <command> [arguments]
+
+commands:
+  login   save the server address and an API token
+  push    create snippets from files, or from stdin
+  get     print the content of a snippet
+  list    list your snippets
+
+Run "snippet <command> -h" for command options.
+`
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	commands := map[string]func([]string) error{
+		"login": cmdLogin,
+		"push":  cmdPush,
+		"get":   cmdGet,
+		"list":  cmdList,
+	}
+
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "snippet: unknown command %q\n\n", flag.Arg(0))
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := cmd(flag.Args()[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "snippet: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// tagsFlag collects a repeated -t flag; each value may also be a comma
+// separated list.
+type tagsFlag []string
+
+func (t *tagsFlag) String() string { return strings.Join(*t, ",") }
+
+func (t *tagsFlag) Set(v string) error {
+	for _, tag := range strings.Split(v, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			*t = append(*t, tag)
+		}
+	}
+	return nil
+}
+
+// parseExpiry converts expiry periods like "7d" or "1y" to days.
+func parseExpiry(s string) (int, error) {
+	n, unit := s, "d"
+	if i := len(s) - 1; i > 0 && (s[i] == 'd' || s[i] == 'y') {
+		n, unit = s[:i], s[i:]
+	}
+
+	days, err := strconv.Atoi(n)
+	if err != nil || days < 1 {
+		return 0, fmt.Errorf("invalid expiry %q, use 1d, 7d or 1y", s)
+	}
+	if unit == "y" {
+		days *= 365
+	}
+
+	return days, nil
+}
+
+func readContent(r io.Reader) (string, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxContentBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxContentBytes {
+		return "", fmt.Errorf("content is larger than %d bytes", maxContentBytes)
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return "", errors.New("content is empty")
+	}
+
+	return string(b), nil
+}
+
+func cmdLogin(args []string) error {
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	server := fs.String("server", defaultServer, "Snippetbox server address")
+	ca := fs.String("ca", "", "also trust the root CA certificate in PEM `file`, e.g. the development server's tls/cert.pem")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: snippet login [-server URL] [-ca FILE]\n\nReads an API token from stdin and saves it with the server address.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := &config{Server: *server}
+	if *ca != "" {
+		// commands may run from any directory
+		path, err := filepath.Abs(*ca)
+		if err != nil {
+			return err
+		}
+		cfg.CA = path
+
+		// fail now rather than on the first command
+		if _, err := newClient(cfg); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Create a token at %s/account/tokens and paste it here: ", strings.TrimSuffix(*server, "/"))
+
+	token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return errors.New("no token given")
+	}
+
+	cfg.Token = token
+	path, err := saveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Saved to %s\n", path)
+	return nil
+}
+
+func cmdPush(args []string) error {
+	fs := flag.NewFlagSet("push", flag.ContinueOnError)
+	expiry := fs.String("e", "1y", "expire after `period`: 1d, 7d or 1y")
+	title := fs.String("title", "", "snippet title (default: the file name)")
+	private := fs.Bool("private", false, "only you can view the snippet")
+	var tags tagsFlag
+	fs.Var(&tags, "t", "add `tag`, may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: snippet push [options] [file...]\n\nCreates a snippet from each file, or from stdin when no files are given.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	days, err := parseExpiry(*expiry)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	input := snippetInput{Expires: days, Tags: tags, Visibility: "public"}
+	if *private {
+		input.Visibility = "private"
+	}
+
+	push := func(title string, r io.Reader) error {
+		content, err := readContent(r)
+		if err != nil {
+			return err
+		}
+
+		input.Title, input.Content = title, content
+		s, err := c.create(input)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(c.viewURL(s.ID))
+		return nil
+	}
+
+	if fs.NArg() == 0 {
+		if *title == "" {
+			*title = "Untitled"
+		}
+		return push(*title, os.Stdin)
+	}
+
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		t := *title
+		if t == "" {
+			t = filepath.Base(name)
+		}
+
+		err = push(t, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func cmdGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: snippet get ID\n\nPrints the raw content of a snippet.")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	id, err := strconv.Atoi(fs.Arg(0))
+	if err != nil || id < 1 {
+		return fmt.Errorf("invalid snippet ID %q", fs.Arg(0))
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	s, err := c.get(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(s.Content)
+	if !strings.HasSuffix(s.Content, "\n") {
+		fmt.Println()
+	}
+
+	return nil
+}
+
+func cmdList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	tag := fs.String("tag", "", "only snippets with `tag`")
+	expired := fs.Bool("expired", false, "list expired snippets instead of active ones")
+	page := fs.Int("page", 1, "page `number`")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: snippet list [options]\n\nLists your snippets, newest first.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(*page))
+	query.Set("expiry", "active")
+	if *expired {
+		query.Set("expiry", "expired")
+	}
+	if *tag != "" {
+		query.Set("tag", *tag)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := newClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	list, err := c.list(query)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEXPIRES\tVISIBILITY\tTITLE\tTAGS")
+	for _, s := range list.Snippets {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Expires.Local().Format("2006-01-02 15:04"), s.Visibility, s.Title, strings.Join(s.Tags, ","))
+	}
+	tw.Flush()
+
+	if list.NextPage != nil {
+		fmt.Fprintf(os.Stderr, "More snippets: snippet list -page %d\n", *list.NextPage)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    int
+		wantErr bool
+	}{
+		{name: "Days", s: "7d", want: 7},
+		{name: "Years", s: "1y", want: 365},
+		{name: "No unit", s: "30", want: 30},
+		{name: "Zero", s: "0d", wantErr: true},
+		{name: "Negative", s: "-1d", wantErr: true},
+		{name: "Unit only", s: "d", wantErr: true},
+		{name: "Unknown unit", s: "2w", wantErr: true},
+		{name: "Empty", s: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExpiry(tt.s)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseExpiry(%q) = %d; want an error", tt.s, got)
+				}
+				return
+			}
+
+			if err != nil || got != tt.want {
+				t.Errorf("parseExpiry(%q) = %d, %v; want %d", tt.s, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagsFlagSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{name: "Single", values: []string{"go"}, want: []string{"go"}},
+		{name: "Repeated", values: []string{"go", "sql"}, want: []string{"go", "sql"}},
+		{name: "Comma separated", values: []string{"go, sql,web"}, want: []string{"go", "sql", "web"}},
+		{name: "Blank entries", values: []string{",go,, ", " "}, want: []string{"go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tags tagsFlag
+			for _, v := range tt.values {
+				if err := tags.Set(v); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if !slices.Equal(tags, tt.want) {
+				t.Errorf("got %q; want %q", tags, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+// maxAPIBodyBytes limits the size of JSON request bodies.
+const maxAPIBodyBytes = 1 << 20
+
+// apiError is the body of every JSON error response.
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+type apiErrorDetail struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// apiSnippet is the JSON representation of a snippet.
+type apiSnippet struct {
+	ID         int               `json:"id"`
+	Title      string            `json:"title"`
+	Content    string            `json:"content"`
+	Created    time.Time         `json:"created"`
+	Expires    time.Time         `json:"expires"`
+	Visibility models.Visibility `json:"visibility"`
+	TeamID     *int64            `json:"team_id,omitempty"`
+	Tags       []string          `json:"tags"`
+	Hidden     bool              `json:"hidden,omitempty"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	as := apiSnippet{
+		ID:         s.ID,
+		Title:      s.Title,
+		Content:    s.Content,
+		Created:    s.Created,
+		Expires:    s.Expires,
+		Visibility: s.Visibility,
+		Tags:       s.Tags,
+		Hidden:     s.Hidden,
+	}
+
+	if s.TeamID.Valid {
+		as.TeamID = &s.TeamID.Int64
+	}
+	if as.Tags == nil {
+		as.Tags = []string{}
+	}
+
+	return as
+}
+
+func (app *application) apiErrorResponse(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, apiError{Error: apiErrorDetail{Status: status, Message: message}})
+}
+
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	app.errorLog.Output(2, err.Error())
+	app.apiErrorResponse(w, http.StatusInternalServerError, "the server encountered a problem and could not process your request")
+}
+
+func (app *application) apiClientError(w http.ResponseWriter, status int) {
+	app.apiErrorResponse(w, status, strings.ToLower(http.StatusText(status)))
+}
+
+// apiValidationError reports the field errors collected by a validator.
+func (app *application) apiValidationError(w http.ResponseWriter, v validator.Validator) {
+	detail := apiErrorDetail{
+		Status:  http.StatusUnprocessableEntity,
+		Message: "validation failed",
+		Fields:  v.FieldErrors,
+	}
+	if len(v.NonFieldErrors) > 0 {
+		detail.Message = strings.Join(v.NonFieldErrors, "; ")
+	}
+
+	app.writeJSON(w, detail.Status, apiError{Error: detail})
+}
+
+// apiModelError maps model errors to responses, falling back to a server
+// error for anything unexpected.
+func (app *application) apiModelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.apiClientError(w, http.StatusNotFound)
+	default:
+		app.apiServerError(w, err)
+	}
+}
+
+// readJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and oversized bodies.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAPIBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("body contains unknown key %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		default:
+			return fmt.Errorf("body contains badly-formed JSON: %v", err)
+		}
+	}
+
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// requireAPIAuthentication is the JSON API's counterpart to
+// requireAuthentication, responding with 401 rather than redirecting.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiErrorResponse(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticateToken resolves a personal API token sent as a bearer token to
+// its user, setting the same context as authenticate does for sessions.
+// Requests without an Authorization header pass through unchanged.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Authorization")
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			app.invalidTokenResponse(w)
+			return
+		}
+
+		userID, apiToken, err := app.apiTokens.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidTokenResponse(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		user, err := app.users.Get(userID)
+		if err != nil {
+			if errors.Is(err, models.ErrNoRecord) {
+				app.invalidTokenResponse(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authenticatedUserContextKey, user)
+		ctx = context.WithValue(ctx, isAuthenticatedContextKey, user.Active())
+		ctx = context.WithValue(ctx, apiTokenContextKey, apiToken)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (app *application) invalidTokenResponse(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiErrorResponse(w, http.StatusUnauthorized, "invalid or expired authentication token")
+}
+
+// requireScope rejects requests authenticated by an API token that hasn't
+// been granted scope.  Session-authenticated requests aren't restricted.
+func (app *application) requireScope(scope models.APIScope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, _ := r.Context().Value(apiTokenContextKey).(*models.APIToken)
+			if token != nil && !token.HasScope(scope) {
+				app.apiErrorResponse(w, http.StatusForbidden, fmt.Sprintf("this token does not have the %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
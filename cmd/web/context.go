@@ -0,0 +1,10 @@
+package main
+
+type contextKey string
+
+const (
+	isAuthenticatedContextKey   = contextKey("isAuthenticated")
+	authenticatedUserContextKey = contextKey("authenticatedUser")
+	apiTokenContextKey          = contextKey("apiToken")
+	graphQLContextKey           = contextKey("graphQL")
+)
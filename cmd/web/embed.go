@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+const (
+	embedWidth    = 600
+	embedHeight   = 400
+	embedCacheAge = 3600
+)
+
+// embedOriginRegex matches an origin that may frame embedded snippets, such
+// as https://wiki.example.com or https://*.example.com:8443.
+var embedOriginRegex = regexp.MustCompile(`^https?://(\*\.)?[A-Za-z0-9.-]+(:[0-9]+)?$`)
+
+// parseEmbedOrigins parses the space separated list of origins given with
+// the -embed-origins flag.
+func parseEmbedOrigins(s string) ([]string, error) {
+	origins := strings.Fields(s)
+
+	for _, o := range origins {
+		if !embedOriginRegex.MatchString(o) {
+			return nil, fmt.Errorf("invalid embed origin %q", o)
+		}
+	}
+
+	return origins, nil
+}
+
+// allowFraming relaxes the headers set by secureHeaders so that the page can
+// be shown in an iframe by the configured embed origins.
+func (app *application) allowFraming(next http.Handler) http.Handler {
+	ancestors := "'self'"
+	if len(app.embedOrigins) > 0 {
+		ancestors += " " + strings.Join(app.embedOrigins, " ")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Del("X-Frame-Options")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy+"; frame-ancestors "+ancestors)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// snippetEmbed shows a snippet on a minimal page for use in an iframe.  It
+// is served without a session, since browsers don't send cookies to
+// third-party frames, so only public snippets and share links work.
+func (app *application) snippetEmbed(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	author, err := app.snippetAuthor(snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := &templateData{
+		CurrentYear: time.Now().Year(),
+		Snippet:     snippet,
+		Author:      author,
+	}
+
+	app.renderNamed(w, http.StatusOK, "embed.tmpl", "embed", data)
+}
+
+// oEmbedResponse is a "rich" oEmbed response, see https://oembed.com.
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	Title        string `json:"title"`
+	AuthorName   string `json:"author_name,omitempty"`
+	AuthorURL    string `json:"author_url,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CacheAge     int64  `json:"cache_age"`
+}
+
+// oEmbedURL returns the oEmbed endpoint URL for a snippet page.
+func (app *application) oEmbedURL(id int) string {
+	return app.origin + "/oembed?url=" + url.QueryEscape(fmt.Sprintf("%s/snippet/view/%d", app.origin, id))
+}
+
+// oEmbedDimension returns def, reduced to the consumer's maximum if given.
+func oEmbedDimension(q url.Values, key string, def int) int {
+	if max, err := strconv.Atoi(q.Get(key)); err == nil && max > 0 && max < def {
+		return max
+	}
+
+	return def
+}
+
+// oEmbed describes how to embed the snippet page given in the "url" query
+// parameter.  As with snippetEmbed, only public snippets and share links can
+// be embedded.
+func (app *application) oEmbed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if format := q.Get("format"); format != "" && format != "json" {
+		app.clientError(w, http.StatusNotImplemented)
+		return
+	}
+
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || u.Scheme+"://"+u.Host != app.origin {
+		app.notFound(w)
+		return
+	}
+
+	idParam, ok := strings.CutPrefix(u.Path, "/snippet/view/")
+	id, err := strconv.Atoi(idParam)
+	if !ok || err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	src := fmt.Sprintf("%s/snippet/embed/%d", app.origin, id)
+	cacheAge := int64(embedCacheAge)
+
+	if snippet.Visibility != models.VisibilityPublic {
+		valid, err := app.validShareLink(u.Query(), snippet)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		if !valid {
+			app.notFound(w)
+			return
+		}
+
+		// keep the share link, and don't let it be cached beyond its expiry
+		src += "?" + url.Values{"expires": {u.Query().Get("expires")}, "sig": {u.Query().Get("sig")}}.Encode()
+		expires, _ := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+		cacheAge = min(cacheAge, expires-time.Now().Unix())
+	}
+
+	if snippet.Hidden {
+		app.notFound(w)
+		return
+	}
+
+	author, err := app.snippetAuthor(snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	width := oEmbedDimension(q, "maxwidth", embedWidth)
+	height := oEmbedDimension(q, "maxheight", embedHeight)
+
+	resp := oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		ProviderName: "Snippetbox",
+		ProviderURL:  app.origin + "/",
+		Title:        snippet.Title,
+		HTML: fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border: 0" loading="lazy"></iframe>`,
+			html.EscapeString(src), width, height, html.EscapeString(snippet.Title)),
+		Width:    width,
+		Height:   height,
+		CacheAge: cacheAge,
+	}
+
+	if author != nil {
+		resp.AuthorName = author.Name
+		resp.AuthorURL = fmt.Sprintf("%s/u/%s", app.origin, author.Handle)
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const feedSize = 20
+
+// feed is what the Atom and RSS formats are rendered from.
+type feed struct {
+	Title    string
+	Link     string // page the feed mirrors
+	Self     string
+	Author   string
+	Updated  time.Time
+	Snippets []*models.Snippet
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// loadFeed collects the latest public snippets, narrowed to one user's by
+// the "user" query parameter and to one tag by "tag".
+func (app *application) loadFeed(r *http.Request) (*feed, error) {
+	f := &feed{
+		Title:  "Snippetbox",
+		Link:   app.origin + "/",
+		Self:   app.origin + r.URL.RequestURI(),
+		Author: "Snippetbox",
+	}
+
+	var userID int
+
+	if handle := strings.ToLower(r.URL.Query().Get("user")); handle != "" {
+		user, err := app.users.GetByHandle(handle)
+		if err != nil {
+			return nil, err
+		}
+
+		userID = user.ID
+		f.Title = fmt.Sprintf("Snippets by %s", user.Name)
+		f.Link = fmt.Sprintf("%s/u/%s", app.origin, user.Handle)
+		f.Author = user.Name
+	}
+
+	tag := strings.ToLower(r.URL.Query().Get("tag"))
+	if tag != "" {
+		if !validator.Matches(tag, validator.TagRegex) {
+			return nil, models.ErrNoRecord
+		}
+
+		f.Title += fmt.Sprintf(" tagged %q", tag)
+	}
+
+	snippets, err := app.snippets.Latest(userID, tag, feedSize)
+	if err != nil {
+		return nil, err
+	}
+	f.Snippets = snippets
+
+	for _, s := range snippets {
+		if m := s.Modified(); m.After(f.Updated) {
+			f.Updated = m
+		}
+	}
+
+	return f, nil
+}
+
+func (app *application) snippetURL(s *models.Snippet) string {
+	return fmt.Sprintf("%s/snippet/view/%d", app.origin, s.ID)
+}
+
+// serveFeed responds with a rendered feed.  http.ServeContent answers
+// conditional requests using the ETag alone, which changes whenever a snippet
+// is added, edited or drops out of the feed.  The newest entry's time is no
+// use as Last-Modified, since entries can drop out without it changing.
+func (app *application) serveFeed(w http.ResponseWriter, r *http.Request, contentType string, v any) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	err := xml.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(buf.Bytes()))
+}
+
+// feedError responds to an error from loadFeed.
+func (app *application) feedError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrNoRecord) {
+		app.notFound(w)
+		return
+	}
+
+	app.serverError(w, err)
+}
+
+func (app *application) feedAtom(w http.ResponseWriter, r *http.Request) {
+	f, err := app.loadFeed(r)
+	if err != nil {
+		app.feedError(w, err)
+		return
+	}
+
+	app.serveFeed(w, r, "application/atom+xml; charset=utf-8", app.newAtomFeed(f))
+}
+
+func (app *application) feedRSS(w http.ResponseWriter, r *http.Request) {
+	f, err := app.loadFeed(r)
+	if err != nil {
+		app.feedError(w, err)
+		return
+	}
+
+	app.serveFeed(w, r, "application/rss+xml; charset=utf-8", app.newRSSFeed(f))
+}
+
+func (app *application) newAtomFeed(f *feed) atomFeed {
+	// Atom requires an updated time even when there are no entries, a fixed
+	// one keeps the ETag of an empty feed stable
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	atom := atomFeed{
+		ID:      f.Self,
+		Title:   f.Title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Type: "text/html", Href: f.Link},
+		},
+		Author: atomAuthor{Name: f.Author},
+	}
+
+	for _, s := range f.Snippets {
+		entry := atomEntry{
+			ID:        app.snippetURL(s),
+			Title:     s.Title,
+			Published: s.Created.UTC().Format(time.RFC3339),
+			Updated:   s.Modified().UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: app.snippetURL(s)},
+			Content:   atomContent{Type: "text", Body: s.Content},
+		}
+		for _, t := range s.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return atom
+}
+
+func (app *application) newRSSFeed(f *feed) rssFeed {
+	rss := rssFeed{
+		Version: "2.0",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			Description: "The latest public snippets on Snippetbox",
+			Self:        atomLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+		},
+	}
+	if !f.Updated.IsZero() {
+		rss.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, s := range f.Snippets {
+		rss.Channel.Items = append(rss.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        app.snippetURL(s),
+			GUID:        rssGUID{IsPermaLink: true, Value: app.snippetURL(s)},
+			PubDate:     s.Created.UTC().Format(time.RFC1123Z),
+			Categories:  s.Tags,
+			Description: s.Content,
+		})
+	}
+
+	return rss
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	// graphQLMaxDepth limits how deeply fields may be nested in a query.
+	graphQLMaxDepth = 8
+	// graphQLMaxComplexity limits the estimated number of values a query
+	// may resolve, see graphQLCost.
+	graphQLMaxComplexity = 1000
+	// graphQLMaxLimit caps the limit argument of list fields.
+	graphQLMaxLimit = 100
+)
+
+var (
+	errGraphQLUnauthenticated = errors.New("you must be authenticated to perform this operation")
+	errGraphQLForbidden       = errors.New("you are not allowed to change this snippet")
+	errGraphQLNotFound        = errors.New("snippet not found")
+	errGraphQLServer          = errors.New("the server encountered a problem and could not process your request")
+)
+
+// graphQLRequest is the body of a POST to /graphql.
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"` // accepted but ignored
+}
+
+// graphQLContext gives resolvers the HTTP request being served, so they
+// can apply the same authorization checks as the HTML handlers, and the
+// loaders that batch their queries.
+type graphQLContext struct {
+	r       *http.Request
+	authors *authorLoader
+}
+
+func graphQLRequestContext(ctx context.Context) *graphQLContext {
+	return ctx.Value(graphQLContextKey).(*graphQLContext)
+}
+
+// graphQLValidationError reports the field errors collected by a validator,
+// listing them in the error's extensions.
+type graphQLValidationError struct {
+	validator.Validator
+}
+
+func (e graphQLValidationError) Error() string {
+	if len(e.NonFieldErrors) > 0 {
+		return strings.Join(e.NonFieldErrors, "; ")
+	}
+
+	return "validation failed"
+}
+
+func (e graphQLValidationError) Extensions() map[string]any {
+	return map[string]any{"fields": e.FieldErrors}
+}
+
+// graphQLServerError logs err and returns an error that is safe to show to
+// clients.
+func (app *application) graphQLServerError(err error) error {
+	app.errorLog.Output(2, err.Error())
+	return errGraphQLServer
+}
+
+func (app *application) graphQLErrorResponse(w http.ResponseWriter, status int, err error) {
+	app.writeJSON(w, status, graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}})
+}
+
+// graphQL executes a GraphQL query or mutation.  The document is parsed and
+// validated up front so that token scopes and the depth and complexity
+// limits can be checked before anything is read from the database.
+func (app *application) graphQL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-store")
+
+	var req graphQLRequest
+
+	err := app.readJSON(w, r, &req)
+	if err != nil {
+		app.graphQLErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		app.graphQLErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	validation := graphql.ValidateDocument(&app.graphQLSchema, doc, nil)
+	if !validation.IsValid {
+		app.writeJSON(w, http.StatusBadRequest, graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	op, err := graphQLOperation(doc, req.OperationName)
+	if err != nil {
+		app.graphQLErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// mutations need the same scope as writes to the JSON API, everything
+	// else the read scope
+	scope := models.ScopeSnippetsRead
+	if op.Operation == ast.OperationTypeMutation {
+		scope = models.ScopeSnippetsWrite
+	}
+
+	token, _ := r.Context().Value(apiTokenContextKey).(*models.APIToken)
+	if token != nil && !token.HasScope(scope) {
+		app.graphQLErrorResponse(w, http.StatusForbidden, fmt.Errorf("this token does not have the %s scope", scope))
+		return
+	}
+
+	cost := graphQLCost{fragments: graphQLFragments(doc), variables: req.Variables}
+
+	complexity, err := cost.selectionSet(op.SelectionSet, graphQLRootType(app.graphQLSchema, op), 1)
+	if err != nil {
+		app.graphQLErrorResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if complexity > graphQLMaxComplexity {
+		app.graphQLErrorResponse(w, http.StatusBadRequest, fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, graphQLMaxComplexity))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLContextKey, &graphQLContext{
+		r:       r,
+		authors: &authorLoader{users: app.users, loaded: make(map[int]*models.User)},
+	})
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        app.graphQLSchema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+
+	app.writeJSON(w, http.StatusOK, result)
+}
+
+// graphQLOperation returns the operation of doc to execute, which must be
+// named when the document holds more than one.
+func graphQLOperation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "" && found != nil:
+			return nil, errors.New("an operation name is required when the query contains several operations")
+		case name == "" || (op.Name != nil && op.Name.Value == name):
+			found = op
+		}
+	}
+
+	if found == nil {
+		if name == "" {
+			return nil, errors.New("the query contains no operations")
+		}
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+
+	if found.Operation == ast.OperationTypeSubscription {
+		return nil, errors.New("subscriptions are not supported")
+	}
+
+	return found, nil
+}
+
+func graphQLRootType(schema graphql.Schema, op *ast.OperationDefinition) *graphql.Object {
+	if op.Operation == ast.OperationTypeMutation {
+		return schema.MutationType()
+	}
+
+	return schema.QueryType()
+}
+
+func graphQLFragments(doc *ast.Document) map[string]*ast.FragmentDefinition {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[frag.Name.Value] = frag
+		}
+	}
+
+	return fragments
+}
+
+// graphQLCost estimates the work a validated query will do.  Each field
+// costs one, and the fields selected below a list are multiplied by the
+// list's limit argument, so asking for the authors of 100 snippets costs
+// as much as it would to ask for 100 authors separately.
+type graphQLCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+func (c *graphQLCost) selectionSet(set *ast.SelectionSet, parent *graphql.Object, depth int) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+
+	if depth > graphQLMaxDepth {
+		return 0, fmt.Errorf("query is nested more than %d levels deep", graphQLMaxDepth)
+	}
+
+	total := 0
+
+	for _, sel := range set.Selections {
+		var n int
+		var err error
+
+		switch sel := sel.(type) {
+		case *ast.Field:
+			n, err = c.field(sel, parent, depth)
+		case *ast.InlineFragment:
+			// the schema has no interfaces or unions, so a fragment's type
+			// is always its parent's
+			n, err = c.selectionSet(sel.SelectionSet, parent, depth)
+		case *ast.FragmentSpread:
+			n, err = c.selectionSet(c.fragments[sel.Name.Value].SelectionSet, parent, depth)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (c *graphQLCost) field(field *ast.Field, parent *graphql.Object, depth int) (int, error) {
+	name := field.Name.Value
+
+	// introspection only reads the schema held in memory, so it's exempt
+	// from the depth limit and each field is counted once
+	if strings.HasPrefix(name, "__") {
+		return c.introspection(field.SelectionSet), nil
+	}
+
+	def := parent.Fields()[name]
+
+	child, list := graphQLNamedType(def.Type)
+	obj, ok := child.(*graphql.Object)
+	if !ok {
+		return 1, nil
+	}
+
+	n, err := c.selectionSet(field.SelectionSet, obj, depth+1)
+	if err != nil {
+		return 0, err
+	}
+
+	if list {
+		n *= c.limit(field, def)
+	}
+
+	return 1 + n, nil
+}
+
+func (c *graphQLCost) introspection(set *ast.SelectionSet) int {
+	if set == nil {
+		return 1
+	}
+
+	total := 1
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			total += c.introspection(sel.SelectionSet)
+		case *ast.InlineFragment:
+			total += c.introspection(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			total += c.introspection(c.fragments[sel.Name.Value].SelectionSet)
+		}
+	}
+
+	return total
+}
+
+// limit returns the limit argument given to a list field, falling back to
+// its default.
+func (c *graphQLCost) limit(field *ast.Field, def *graphql.FieldDefinition) int {
+	limit := 1
+	for _, arg := range def.Args {
+		if arg.Name() == "limit" {
+			if n, ok := arg.DefaultValue.(int); ok {
+				limit = n
+			}
+		}
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				limit = n
+			}
+		case *ast.Variable:
+			// variables decoded from JSON are float64
+			if n, ok := c.variables[v.Name.Value].(float64); ok {
+				limit = int(n)
+			}
+		}
+	}
+
+	return clampLimit(limit)
+}
+
+// graphQLNamedType strips the list and non-null wrappers from t, reporting
+// whether it was a list.
+func graphQLNamedType(t graphql.Type) (graphql.Type, bool) {
+	list := false
+	for {
+		switch wrapped := t.(type) {
+		case *graphql.NonNull:
+			t = wrapped.OfType
+		case *graphql.List:
+			list = true
+			t = wrapped.OfType
+		default:
+			return t, list
+		}
+	}
+}
+
+func clampLimit(n int) int {
+	return max(1, min(n, graphQLMaxLimit))
+}
+
+// authorLoader batches the lookups of snippet authors made while executing
+// a query.  Resolvers register the IDs they need and return a thunk, and
+// the executor only calls the thunks once the snippets at that level have
+// all been resolved, so the first thunk loads every pending author with a
+// single query.
+type authorLoader struct {
+	users   models.UserModelInterface
+	pending []int
+	loaded  map[int]*models.User // nil for missing and inactive users
+}
+
+func (l *authorLoader) load(id int) func() (any, error) {
+	if _, ok := l.loaded[id]; !ok {
+		l.pending = append(l.pending, id)
+	}
+
+	return func() (any, error) {
+		if len(l.pending) > 0 {
+			users, err := l.users.GetMany(l.pending)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, id := range l.pending {
+				l.loaded[id] = nil
+			}
+			for _, user := range users {
+				if user.Active() {
+					l.loaded[user.ID] = user
+				}
+			}
+
+			l.pending = nil
+		}
+
+		if user := l.loaded[id]; user != nil {
+			return user, nil
+		}
+
+		return nil, nil
+	}
+}
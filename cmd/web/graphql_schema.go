@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"snippetbox.mattman.net/internal/models"
+)
+
+// newGraphQLSchema builds the schema served at /graphql.  Resolvers apply
+// the same authorization rules as the HTML handlers: snippets the current
+// user may not view are reported as null rather than forbidden, so their
+// existence isn't revealed.
+func (app *application) newGraphQLSchema() (graphql.Schema, error) {
+	visibilityValues := graphql.EnumValueConfigMap{}
+	for _, v := range models.Visibilities {
+		visibilityValues[strings.ToUpper(string(v))] = &graphql.EnumValueConfig{Value: v}
+	}
+
+	visibilityEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:   "Visibility",
+		Values: visibilityValues,
+	})
+
+	expiryEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Expiry",
+		Values: graphql.EnumValueConfigMap{
+			"ACTIVE":  &graphql.EnumValueConfig{Value: models.ExpiryActive},
+			"EXPIRED": &graphql.EnumValueConfig{Value: models.ExpiryExpired},
+		},
+	})
+
+	sortValues := graphql.EnumValueConfigMap{}
+	for _, sort := range models.SnippetSorts {
+		sortValues[strings.ToUpper(sort)] = &graphql.EnumValueConfig{Value: sort}
+	}
+
+	sortEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name:   "SnippetSort",
+		Values: sortValues,
+	})
+
+	// only what is shown on public profiles
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"handle":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"created": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	snippetType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Snippet",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"title":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"content":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"created":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"expires":    &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"visibility": &graphql.Field{Type: graphql.NewNonNull(visibilityEnum)},
+			"hidden":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"updated": &graphql.Field{
+				Type: graphql.DateTime,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					s := p.Source.(*models.Snippet)
+					if !s.Updated.Valid {
+						return nil, nil
+					}
+					return s.Updated.Time, nil
+				},
+			},
+			"tags": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					s := p.Source.(*models.Snippet)
+					if s.Tags == nil {
+						return []string{}, nil
+					}
+					return s.Tags, nil
+				},
+			},
+			"author": &graphql.Field{
+				Type:        userType,
+				Description: "The snippet's owner, null if it has none or the account is no longer active.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					s := p.Source.(*models.Snippet)
+					if !s.UserID.Valid {
+						return nil, nil
+					}
+
+					thunk := graphQLRequestContext(p.Context).authors.load(int(s.UserID.Int64))
+					return func() (any, error) {
+						user, err := thunk()
+						if err != nil {
+							return nil, app.graphQLServerError(err)
+						}
+						return user, nil
+					}, nil
+				},
+			},
+		},
+	})
+
+	limitArg := func(def int) *graphql.ArgumentConfig {
+		return &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: def}
+	}
+
+	viewerType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Viewer",
+		Description: "The authenticated user.",
+		Fields: graphql.Fields{
+			"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"handle":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"name":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"created": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"role": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return string(p.Source.(*models.User).Role), nil
+				},
+			},
+			"snippets": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(snippetType))),
+				Description: "The viewer's own snippets, including expired, private and hidden ones, like /snippet/mine.",
+				Args: graphql.FieldConfigArgument{
+					"tag":        &graphql.ArgumentConfig{Type: graphql.String},
+					"visibility": &graphql.ArgumentConfig{Type: visibilityEnum},
+					"expiry":     &graphql.ArgumentConfig{Type: expiryEnum},
+					"sort":       &graphql.ArgumentConfig{Type: sortEnum},
+					"limit":      limitArg(minePageSize),
+					"offset":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user := p.Source.(*models.User)
+
+					var filter models.SnippetFilter
+					filter.Tag, _ = p.Args["tag"].(string)
+					filter.Visibility, _ = p.Args["visibility"].(models.Visibility)
+					filter.Expiry, _ = p.Args["expiry"].(string)
+					filter.Sort, _ = p.Args["sort"].(string)
+
+					offset, _ := p.Args["offset"].(int)
+
+					snippets, err := app.snippets.Filter(user.ID, filter, clampLimit(p.Args["limit"].(int)), max(offset, 0))
+					if err != nil {
+						return nil, app.graphQLServerError(err)
+					}
+					return snippets, nil
+				},
+			},
+			"tags": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+				Description: "Every tag used on the viewer's snippets.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					tags, err := app.snippets.Tags(p.Source.(*models.User).ID)
+					if err != nil {
+						return nil, app.graphQLServerError(err)
+					}
+					return tags, nil
+				},
+			},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"viewer": &graphql.Field{
+				Type:        viewerType,
+				Description: "The authenticated user, null when not logged in.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					r := graphQLRequestContext(p.Context).r
+					if !app.isAuthenticated(r) {
+						return nil, nil
+					}
+					return app.authenticatedUser(r), nil
+				},
+			},
+			"snippet": &graphql.Field{
+				Type: snippetType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					snippet, err := app.graphQLSnippet(p)
+					if errors.Is(err, errGraphQLNotFound) {
+						return nil, nil
+					}
+					return snippet, err
+				},
+			},
+			"snippets": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(snippetType))),
+				Description: "The latest public snippets, optionally only those by author or carrying tag.",
+				Args: graphql.FieldConfigArgument{
+					"author": &graphql.ArgumentConfig{Type: graphql.String, Description: "a user's handle"},
+					"tag":    &graphql.ArgumentConfig{Type: graphql.String},
+					"limit":  limitArg(10),
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					userID := 0
+					if handle, ok := p.Args["author"].(string); ok {
+						user, err := app.users.GetByHandle(handle)
+						if err != nil {
+							if errors.Is(err, models.ErrNoRecord) {
+								return []*models.Snippet{}, nil
+							}
+							return nil, app.graphQLServerError(err)
+						}
+						userID = user.ID
+					}
+
+					tag, _ := p.Args["tag"].(string)
+
+					snippets, err := app.snippets.Latest(userID, tag, clampLimit(p.Args["limit"].(int)))
+					if err != nil {
+						return nil, app.graphQLServerError(err)
+					}
+					return snippets, nil
+				},
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"handle": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					user, err := app.users.GetByHandle(p.Args["handle"].(string))
+					if err != nil {
+						if errors.Is(err, models.ErrNoRecord) {
+							return nil, nil
+						}
+						return nil, app.graphQLServerError(err)
+					}
+					return user, nil
+				},
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createSnippet": &graphql.Field{
+				Type:        graphql.NewNonNull(snippetType),
+				Description: "Creates a snippet, with the same rules as /snippet/create.",
+				Args: graphql.FieldConfigArgument{
+					"title":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"content":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"expires":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 365, Description: "days: 1, 7 or 365"},
+					"visibility": &graphql.ArgumentConfig{Type: visibilityEnum, DefaultValue: models.VisibilityPublic},
+					"teamId":     &graphql.ArgumentConfig{Type: graphql.Int},
+					"tags":       &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: app.graphQLCreateSnippet,
+			},
+			"updateSnippet": &graphql.Field{
+				Type:        graphql.NewNonNull(snippetType),
+				Description: "Replaces the title, content and tags of a snippet the viewer may edit.",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					"title":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"content": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"tags":    &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+				},
+				Resolve: app.graphQLUpdateSnippet,
+			},
+			"deleteSnippet": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Int),
+				Description: "Permanently removes a snippet owned by the viewer, returning its ID.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: app.graphQLDeleteSnippet,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+// graphQLSnippet loads the snippet named by the id argument, returning
+// errGraphQLNotFound if it doesn't exist or the current user may not read
+// it.
+func (app *application) graphQLSnippet(p graphql.ResolveParams) (*models.Snippet, error) {
+	snippet, err := app.snippets.Get(p.Args["id"].(int))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, errGraphQLNotFound
+		}
+		return nil, app.graphQLServerError(err)
+	}
+
+	ok, err := app.canViewSnippet(graphQLRequestContext(p.Context).r, snippet)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+	if !ok {
+		return nil, errGraphQLNotFound
+	}
+
+	return snippet, nil
+}
+
+// graphQLTags joins a list of tags given as an argument the way they are
+// entered in the HTML forms.
+func graphQLTags(arg any) string {
+	list, _ := arg.([]any)
+
+	tags := make([]string, 0, len(list))
+	for _, tag := range list {
+		if s, ok := tag.(string); ok {
+			tags = append(tags, s)
+		}
+	}
+
+	return strings.Join(tags, ",")
+}
+
+func (app *application) graphQLCreateSnippet(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequestContext(p.Context).r
+	if !app.isAuthenticated(r) {
+		return nil, errGraphQLUnauthenticated
+	}
+
+	form := snippetCreateForm{
+		Title:   p.Args["title"].(string),
+		Content: p.Args["content"].(string),
+		Tags:    graphQLTags(p.Args["tags"]),
+	}
+	form.Expires, _ = p.Args["expires"].(int)
+	form.Team, _ = p.Args["teamId"].(int)
+	if v, ok := p.Args["visibility"].(models.Visibility); ok {
+		form.Visibility = string(v)
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	teams, err := app.teams.ForUser(userID)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	tags := form.check(teams)
+	if !form.Valid() {
+		return nil, graphQLValidationError{form.Validator}
+	}
+
+	id, err := app.snippets.Insert(userID, form.Title, form.Content, form.Expires, models.Visibility(form.Visibility), form.Team, tags)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	app.queueSnippetEvent(models.EventSnippetCreated, snippet)
+
+	return snippet, nil
+}
+
+func (app *application) graphQLUpdateSnippet(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequestContext(p.Context).r
+	if !app.isAuthenticated(r) {
+		return nil, errGraphQLUnauthenticated
+	}
+
+	snippet, err := app.graphQLSnippet(p)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := app.canEditSnippet(r, snippet)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+	if !canEdit {
+		return nil, errGraphQLForbidden
+	}
+
+	form := snippetEditForm{
+		Title:   p.Args["title"].(string),
+		Content: p.Args["content"].(string),
+		Tags:    graphQLTags(p.Args["tags"]),
+	}
+
+	tags := form.check()
+	if !form.Valid() {
+		return nil, graphQLValidationError{form.Validator}
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, tags)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	snippet, err = app.snippets.Get(snippet.ID)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	app.queueSnippetEvent(models.EventSnippetUpdated, snippet)
+
+	return snippet, nil
+}
+
+func (app *application) graphQLDeleteSnippet(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequestContext(p.Context).r
+	if !app.isAuthenticated(r) {
+		return nil, errGraphQLUnauthenticated
+	}
+
+	snippet, err := app.graphQLSnippet(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if !app.isSnippetOwner(r, snippet) {
+		return nil, errGraphQLForbidden
+	}
+
+	owner, err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		return nil, app.graphQLServerError(err)
+	}
+
+	app.queueWebhookEvent(owner, models.EventSnippetDeleted, deletedSnippet{ID: snippet.ID})
+
+	return snippet.ID, nil
+}
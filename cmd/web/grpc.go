@@ -0,0 +1,440 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/snippetpb"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	grpcPageSize = apiPageSize
+	// grpcWatchInterval is how often WatchSnippets polls for new snippets.
+	grpcWatchInterval = 2 * time.Second
+	// grpcMaxWatchers bounds the WatchSnippets streams open at once, since
+	// each one polls the database.
+	grpcMaxWatchers = 100
+)
+
+// grpcScopes maps each SnippetService method to the API token scope it
+// requires, matching the JSON API routes.
+var grpcScopes = map[string]models.APIScope{
+	snippetpb.SnippetService_CreateSnippet_FullMethodName: models.ScopeSnippetsWrite,
+	snippetpb.SnippetService_GetSnippet_FullMethodName:    models.ScopeSnippetsRead,
+	snippetpb.SnippetService_ListSnippets_FullMethodName:  models.ScopeSnippetsRead,
+	snippetpb.SnippetService_DeleteSnippet_FullMethodName: models.ScopeSnippetsWrite,
+	snippetpb.SnippetService_WatchSnippets_FullMethodName: models.ScopeSnippetsRead,
+}
+
+var grpcVisibilities = map[models.Visibility]snippetpb.Visibility{
+	models.VisibilityPublic:  snippetpb.Visibility_VISIBILITY_PUBLIC,
+	models.VisibilityPrivate: snippetpb.Visibility_VISIBILITY_PRIVATE,
+	models.VisibilityTeam:    snippetpb.Visibility_VISIBILITY_TEAM,
+}
+
+// modelVisibility converts a visibility from a request, returning "" for
+// VISIBILITY_UNSPECIFIED.
+func modelVisibility(v snippetpb.Visibility) models.Visibility {
+	for vis, pb := range grpcVisibilities {
+		if pb == v {
+			return vis
+		}
+	}
+
+	return ""
+}
+
+func newGRPCSnippet(s *models.Snippet) *snippetpb.Snippet {
+	ps := &snippetpb.Snippet{
+		Id:         int64(s.ID),
+		Title:      s.Title,
+		Content:    s.Content,
+		Created:    timestamppb.New(s.Created),
+		Expires:    timestamppb.New(s.Expires),
+		Visibility: grpcVisibilities[s.Visibility],
+		TeamId:     s.TeamID.Int64,
+		Tags:       s.Tags,
+		Hidden:     s.Hidden,
+	}
+
+	if s.Updated.Valid {
+		ps.Updated = timestamppb.New(s.Updated.Time)
+	}
+
+	return ps
+}
+
+// snippetServer implements the gRPC SnippetService with the same models and
+// authorization helpers as the web handlers.
+type snippetServer struct {
+	snippetpb.UnimplementedSnippetServiceServer
+	app *application
+	// watchers holds a token for each open WatchSnippets stream
+	watchers chan struct{}
+}
+
+// serveGRPC serves the SnippetService on its own port, with the same TLS
+// certificate as the web server.
+func (app *application) serveGRPC(addr string, tlsConfig *tls.Config) error {
+	cert, err := tls.LoadX509KeyPair("./tls/cert.pem", "./tls/key.pem")
+	if err != nil {
+		return err
+	}
+
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.Certificates = []tls.Certificate{cert}
+
+	srv := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(tlsConfig)),
+		grpc.ChainUnaryInterceptor(app.grpcRecoverUnary, app.grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(app.grpcRecoverStream, app.grpcStreamInterceptor),
+	)
+
+	snippetpb.RegisterSnippetServiceServer(srv, &snippetServer{app: app, watchers: make(chan struct{}, grpcMaxWatchers)})
+	// lets tools such as grpcurl discover the service
+	reflection.Register(srv)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	app.infoLog.Printf("Starting gRPC server on %s", addr)
+	return srv.Serve(lis)
+}
+
+// grpcRecoverUnary is the gRPC counterpart to recoverPanic, turning a panic
+// in a call into an Internal status rather than crashing the process.
+func (app *application) grpcRecoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.grpcServerError(fmt.Errorf("%s: %v", info.FullMethod, p))
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+func (app *application) grpcRecoverStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = app.grpcServerError(fmt.Errorf("%s: %v", info.FullMethod, p))
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+func (app *application) grpcUnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := app.grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (app *application) grpcStreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := app.grpcAuthenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &grpcServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// grpcServerStream replaces the context of a stream with the authenticated
+// one.
+type grpcServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthenticate logs a call and resolves the API token sent as
+// "authorization: Bearer" metadata to its user, returning a context with the
+// same values authenticateToken sets for HTTP requests.  Unlike the JSON
+// API, every call must be authenticated.
+func (app *application) grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+	remote := "-"
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	app.infoLog.Printf("%s - gRPC %s", remote, method)
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "you must be authenticated to access this service")
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errGRPCInvalidToken
+	}
+
+	userID, apiToken, err := app.apiTokens.Authenticate(strings.TrimSpace(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, errGRPCInvalidToken
+		}
+		return nil, app.grpcServerError(err)
+	}
+
+	user, err := app.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, errGRPCInvalidToken
+		}
+		return nil, app.grpcServerError(err)
+	}
+
+	if !user.Active() {
+		return nil, errGRPCInvalidToken
+	}
+
+	if scope, ok := grpcScopes[method]; ok && !apiToken.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "this token does not have the %s scope", scope)
+	}
+
+	ctx = context.WithValue(ctx, authenticatedUserContextKey, user)
+	ctx = context.WithValue(ctx, isAuthenticatedContextKey, true)
+	ctx = context.WithValue(ctx, apiTokenContextKey, apiToken)
+
+	return ctx, nil
+}
+
+var errGRPCInvalidToken = status.Error(codes.Unauthenticated, "invalid or expired authentication token")
+
+// grpcServerError logs err and returns a status that is safe to show to
+// clients.
+func (app *application) grpcServerError(err error) error {
+	app.errorLog.Output(2, err.Error())
+	return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+}
+
+// grpcValidationError reports the field errors collected by a validator as
+// BadRequest details.
+func (app *application) grpcValidationError(v validator.Validator) error {
+	message := "validation failed"
+	if len(v.NonFieldErrors) > 0 {
+		message = strings.Join(v.NonFieldErrors, "; ")
+	}
+
+	details := &errdetails.BadRequest{}
+	for field, description := range v.FieldErrors {
+		details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: description,
+		})
+	}
+
+	st, err := status.New(codes.InvalidArgument, message).WithDetails(details)
+	if err != nil {
+		return app.grpcServerError(err)
+	}
+
+	return st.Err()
+}
+
+// grpcRequest wraps the context of a call in an HTTP request, so that the
+// authorization helpers shared with the web handlers apply unchanged.
+func grpcRequest(ctx context.Context) *http.Request {
+	r, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+	return r
+}
+
+// snippet loads a snippet the caller may view, reporting NotFound if it
+// doesn't exist or they may not.
+func (s *snippetServer) snippet(r *http.Request, id int64) (*models.Snippet, error) {
+	snippet, err := s.app.snippets.Get(int(id))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, status.Error(codes.NotFound, "snippet not found")
+		}
+		return nil, s.app.grpcServerError(err)
+	}
+
+	ok, err := s.app.canViewSnippet(r, snippet)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+	if !ok {
+		return nil, status.Error(codes.NotFound, "snippet not found")
+	}
+
+	return snippet, nil
+}
+
+// CreateSnippet applies the same validation rules as snippetCreatePost.
+func (s *snippetServer) CreateSnippet(ctx context.Context, req *snippetpb.CreateSnippetRequest) (*snippetpb.Snippet, error) {
+	r := grpcRequest(ctx)
+	// grpcAuthenticate guarantees a user, there is no session to fall back on
+	userID := s.app.authenticatedUser(r).ID
+
+	form := snippetCreateForm{
+		Title:      req.GetTitle(),
+		Content:    req.GetContent(),
+		Expires:    int(req.GetExpiresDays()),
+		Visibility: string(modelVisibility(req.GetVisibility())),
+		Tags:       strings.Join(req.GetTags(), ","),
+		Team:       int(req.GetTeamId()),
+	}
+
+	if form.Expires == 0 {
+		form.Expires = 365
+	}
+	if form.Visibility == "" {
+		form.Visibility = string(models.VisibilityPublic)
+	}
+
+	teams, err := s.app.teams.ForUser(userID)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+
+	tags := form.check(teams)
+	if !form.Valid() {
+		return nil, s.app.grpcValidationError(form.Validator)
+	}
+
+	id, err := s.app.snippets.Insert(userID, form.Title, form.Content, form.Expires, models.Visibility(form.Visibility), form.Team, tags)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+
+	snippet, err := s.app.snippets.Get(id)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+
+	s.app.queueSnippetEvent(models.EventSnippetCreated, snippet)
+
+	return newGRPCSnippet(snippet), nil
+}
+
+func (s *snippetServer) GetSnippet(ctx context.Context, req *snippetpb.GetSnippetRequest) (*snippetpb.Snippet, error) {
+	snippet, err := s.snippet(grpcRequest(ctx), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return newGRPCSnippet(snippet), nil
+}
+
+// ListSnippets returns a page of the caller's snippets, like apiSnippetList.
+func (s *snippetServer) ListSnippets(ctx context.Context, req *snippetpb.ListSnippetsRequest) (*snippetpb.ListSnippetsResponse, error) {
+	filter := models.SnippetFilter{
+		Tag:        req.GetTag(),
+		Visibility: modelVisibility(req.GetVisibility()),
+	}
+
+	page := min(max(int(req.GetPage()), 1), maxPage)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := s.app.snippets.Filter(s.app.authenticatedUser(grpcRequest(ctx)).ID, filter, grpcPageSize+1, (page-1)*grpcPageSize)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+
+	resp := &snippetpb.ListSnippetsResponse{}
+	if len(snippets) > grpcPageSize {
+		snippets = snippets[:grpcPageSize]
+		if page < maxPage {
+			resp.NextPage = int32(page + 1)
+		}
+	}
+
+	for _, snippet := range snippets {
+		resp.Snippets = append(resp.Snippets, newGRPCSnippet(snippet))
+	}
+
+	return resp, nil
+}
+
+// DeleteSnippet permanently removes a snippet owned by the caller.
+func (s *snippetServer) DeleteSnippet(ctx context.Context, req *snippetpb.DeleteSnippetRequest) (*snippetpb.DeleteSnippetResponse, error) {
+	r := grpcRequest(ctx)
+
+	snippet, err := s.snippet(r, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.app.isSnippetOwner(r, snippet) {
+		return nil, status.Error(codes.PermissionDenied, "you may only delete your own snippets")
+	}
+
+	owner, err := s.app.snippets.Delete(snippet.ID)
+	if err != nil {
+		return nil, s.app.grpcServerError(err)
+	}
+
+	s.app.queueWebhookEvent(owner, models.EventSnippetDeleted, deletedSnippet{ID: snippet.ID})
+
+	return &snippetpb.DeleteSnippetResponse{}, nil
+}
+
+// WatchSnippets polls for public snippets created since the last poll,
+// the same snippets that appear in the feeds, until the client cancels.
+// Once grpcMaxWatchers streams are open, further calls are refused.
+func (s *snippetServer) WatchSnippets(req *snippetpb.WatchSnippetsRequest, stream grpc.ServerStreamingServer[snippetpb.Snippet]) error {
+	select {
+	case s.watchers <- struct{}{}:
+		defer func() { <-s.watchers }()
+	default:
+		return status.Error(codes.ResourceExhausted, "too many snippets are being watched, try again later")
+	}
+
+	after := int(req.GetAfterId())
+	if after <= 0 {
+		var err error
+		after, err = s.app.snippets.LatestID()
+		if err != nil {
+			return s.app.grpcServerError(err)
+		}
+	}
+
+	ticker := time.NewTicker(grpcWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		snippets, err := s.app.snippets.PublicAfter(after, req.GetTag(), 100)
+		if err != nil {
+			return s.app.grpcServerError(err)
+		}
+
+		for _, snippet := range snippets {
+			if err := stream.Send(newGRPCSnippet(snippet)); err != nil {
+				return err
+			}
+			after = snippet.ID
+		}
+	}
+}
@@ -3,8 +3,10 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"snippetbox.mattman.net/internal/models"
@@ -13,6 +15,7 @@ import (
 
 type userSignupForm struct {
 	Name                string `form:"name"`
+	Handle              string `form:"handle"`
 	Email               string `form:"email"`
 	Password            string `form:"password"`
 	validator.Validator `form:"-"`
@@ -21,6 +24,7 @@ type userSignupForm struct {
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
+	Remember            bool   `form:"remember"`
 	validator.Validator `form:"-"`
 }
 
@@ -28,13 +32,84 @@ type snippetCreateForm struct {
 	Title               string `form:"title"`
 	Content             string `form:"content"`
 	Expires             int    `form:"expires"`
+	Visibility          string `form:"visibility"`
+	Tags                string `form:"tags"`
+	Team                int    `form:"team"`
 	validator.Validator `form:"-"`
 }
 
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Content             string `form:"content"`
+	Tags                string `form:"tags"`
+	validator.Validator `form:"-"`
+}
+
+// checkSnippet validates the fields shared by new and edited snippets and
+// returns the parsed tags.
+func checkSnippet(v *validator.Validator, title, content, tagList string) []string {
+	v.CheckField(validator.NotBlank(title), "title", "This Field cannot be blank")
+	v.CheckField(validator.MaxChars(title, 100), "title", "This field cannot be more than 100 characters long")
+	v.CheckField(validator.NotBlank(content), "content", "This Field cannot be blank")
+
+	tags := parseTags(tagList)
+	v.CheckField(len(tags) <= maxTags, "tags", fmt.Sprintf("There cannot be more than %d tags", maxTags))
+	for _, tag := range tags {
+		v.CheckField(validator.Matches(tag, validator.TagRegex), "tags", "Tags may only contain letters, digits, hyphens and underscores")
+	}
+
+	return tags
+}
+
+// check validates a new snippet by an author belonging to teams, and
+// returns its parsed tags.
+func (form *snippetCreateForm) check(teams []*models.Team) []string {
+	tags := checkSnippet(&form.Validator, form.Title, form.Content, form.Tags)
+
+	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must be equal to 1, 7, or 365")
+	form.CheckField(models.Visibility(form.Visibility).Valid(), "visibility", "This field must be public, private or team")
+
+	if models.Visibility(form.Visibility) == models.VisibilityTeam {
+		form.CheckField(teamIncludes(teams, form.Team), "team", "Choose one of your teams")
+	}
+
+	return tags
+}
+
+// check validates an edited snippet and returns its parsed tags.
+func (form *snippetEditForm) check() []string {
+	return checkSnippet(&form.Validator, form.Title, form.Content, form.Tags)
+}
+
+// home lists the latest public snippets as HTML, JSON or plain text
+// depending on the Accept header.
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	format := negotiate(w, r, mediaHTML, mediaJSON, mediaText)
+
+	snippets, err := app.snippets.Latest(0, "", 10)
 	if err != nil {
-		app.serverError(w, err)
+		if format == mediaJSON {
+			app.apiServerError(w, err)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	switch format {
+	case mediaJSON:
+		list := make([]apiSnippet, 0, len(snippets))
+		for _, s := range snippets {
+			list = append(list, newAPISnippet(s))
+		}
+
+		app.writeJSON(w, http.StatusOK, map[string]any{"snippets": list})
+		return
+	case mediaText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, s := range snippets {
+			fmt.Fprintf(w, "%s/snippet/view/%d\t%s\n", app.origin, s.ID, s.Title)
+		}
 		return
 	}
 
@@ -44,38 +119,158 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	app.render(w, http.StatusOK, "home.tmpl", data)
 }
 
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+// lookupSnippet loads the snippet named in the URL, returning ErrNoRecord
+// if it doesn't exist or the current user may not read it.
+func (app *application) lookupSnippet(r *http.Request) (*models.Snippet, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 
 	id, err := strconv.Atoi(params.ByName("id"))
 	if err != nil || id < 1 {
-		app.notFound(w)
-		return
+		return nil, models.ErrNoRecord
 	}
 
 	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := app.canViewSnippet(r, snippet)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return snippet, nil
+}
+
+// requestSnippet loads the snippet named in the URL, responding with 404
+// if it doesn't exist or the current user may not read it.
+func (app *application) requestSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.lookupSnippet(r)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
-			return
+			return nil, false
 		}
 
 		// some other error
 		app.serverError(w, err)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// requestEditableSnippet is like requestSnippet but responds with 403
+// unless the current user may edit the snippet.
+func (app *application) requestEditableSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.requestSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	ok, err := app.canEditSnippet(r, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return nil, false
+	}
+	if !ok {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// snippetView shows a snippet as a page, as JSON like the API, or as its raw
+// content depending on the Accept header.
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	switch negotiate(w, r, mediaHTML, mediaJSON, mediaText) {
+	case mediaJSON:
+		app.apiSnippetGet(w, r)
+		return
+	case mediaText:
+		app.snippetViewText(w, r)
+		return
+	}
+
+	snippet, ok := app.requestSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := app.snippetViewData(r, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
+
+func (app *application) snippetViewText(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestSnippet(w, r)
+	if !ok {
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, snippet.Content)
+}
+
+// snippetViewData gathers what view.tmpl needs to show a snippet to the
+// current user.
+func (app *application) snippetViewData(r *http.Request, snippet *models.Snippet) (*templateData, error) {
+	author, err := app.snippetAuthor(snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := app.canEditSnippet(r, snippet)
+	if err != nil {
+		return nil, err
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Author = author
+	data.CanEdit = canEdit
+	data.IsOwner = app.isSnippetOwner(r, snippet)
 
-	app.render(w, http.StatusOK, "view.tmpl", data)
+	if snippet.Visibility == models.VisibilityPublic && !snippet.Hidden {
+		data.OEmbedURL = app.oEmbedURL(snippet.ID)
+	}
+
+	// only the owner gets to see and manage who else has access
+	if data.IsOwner {
+		data.Shares, err = app.shares.ForSnippet(snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.SharePermissions = models.SharePermissions
+		data.Form = snippetShareForm{Permission: string(models.ShareRead)}
+	}
+
+	return data, nil
 }
 
 // placeholder handler for displaying snippet create form
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	var err error
+
 	data := app.newTemplateData(r)
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Expires:    365,
+		Visibility: string(models.VisibilityPublic),
+	}
+	data.Visibilities = models.Visibilities
+
+	data.Teams, err = app.teams.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
 	}
 
 	app.render(w, http.StatusOK, "create.tmpl", data)
@@ -90,24 +285,31 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This Field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This Field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must be equal to 1, 7, or 365")
+	teams, err := app.teams.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	tags := form.check(teams)
 
 	if !form.Valid() {
 		data := app.newTemplateData(r)
 		data.Form = form
+		data.Visibilities = models.Visibilities
+		data.Teams = teams
 		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
 		return
 	}
 
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	id, err := app.snippets.Insert(app.authenticatedUserID(r), form.Title, form.Content, form.Expires, models.Visibility(form.Visibility), form.Team, tags)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
+	app.queueSnippetEventByID(models.EventSnippetCreated, id)
+
 	// store flash message  presented to user after redirect
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
@@ -115,6 +317,97 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
 }
 
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestEditableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:   snippet.Title,
+		Content: snippet.Content,
+		Tags:    strings.Join(snippet.Tags, ", "),
+	}
+
+	app.render(w, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestEditableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	tags := form.check()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, tags)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.queueSnippetEventByID(models.EventSnippetUpdated, snippet.ID)
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet updated.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetHidePost lets a moderator hide or reveal a snippet.
+func (app *application) snippetHidePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	hidden := r.PostForm.Get("hidden") == "true"
+
+	err = app.snippets.SetHidden(id, hidden)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if hidden {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet hidden.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "Snippet is visible again.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+}
+
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 	data.Form = userSignupForm{}
@@ -130,7 +423,11 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	form.Handle = strings.ToLower(strings.TrimSpace(form.Handle))
+
 	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Handle), "handle", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Handle, validator.HandleRegex), "handle", "This must be 3 to 30 letters, digits or underscores")
 	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
 	form.CheckField(validator.Matches(form.Email, validator.EmailRegex), "email", "This must be a valid email address")
 	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
@@ -144,11 +441,15 @@ func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Name, form.Email, form.Password)
+	err = app.users.Insert(form.Name, form.Handle, form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrDuplicateEmail) {
-			form.AddFieldError("email", "Email address already in use")
-			// app.infoLog.Printf("email already in use %q", form.Email)
+		if errors.Is(err, models.ErrDuplicateEmail) || errors.Is(err, models.ErrDuplicateHandle) {
+			if errors.Is(err, models.ErrDuplicateEmail) {
+				form.AddFieldError("email", "Email address already in use")
+				// app.infoLog.Printf("email already in use %q", form.Email)
+			} else {
+				form.AddFieldError("handle", "Handle already taken")
+			}
 
 			data := app.newTemplateData(r)
 			data.Form = form
@@ -189,44 +490,74 @@ func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
 		data := app.newTemplateData(r)
 		data.Form = form
 		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
+		return
 	}
 
 	id, err := app.users.Authenticate(form.Email, form.Password)
 	if err != nil {
-		if errors.Is(err, models.ErrInvalidCredentials) {
+		switch {
+		case errors.Is(err, models.ErrInvalidCredentials):
 			form.AddNonFieldError("Email or password is incorrect")
-
-			data := app.newTemplateData(r)
-			data.Form = form
-			app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
-		} else {
+		case errors.Is(err, models.ErrAccountDisabled):
+			form.AddNonFieldError("Your account has been disabled")
+		default:
 			// other error
 			app.serverError(w, err)
+			return
 		}
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login.tmpl", data)
 		return
 	}
 
-	err = app.sessionManager.RenewToken(r.Context())
+	// users with 2FA enabled must enter a code before being logged in
+	enabled, err := app.totp.Enabled(id)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.sessionManager.Put(r.Context(), sessionUserIdKey, id)
+	if enabled {
+		err = app.sessionManager.RenewToken(r.Context())
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
 
-	http.Redirect(w, r, "/snippet/create", http.StatusSeeOther)
+		app.sessionManager.Put(r.Context(), sessionPendingUserIdKey, id)
+		app.sessionManager.Put(r.Context(), sessionPendingRememberKey, form.Remember)
+		app.sessionManager.Remove(r.Context(), sessionPendingAttemptsKey)
+
+		http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+		return
+	}
+
+	err = app.logIn(r, id, form.Remember)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, app.loginRedirect(r), http.StatusSeeOther)
 }
 
 func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
-	// change session ID for added security
-	err := app.sessionManager.RenewToken(r.Context())
+	err := app.userSessions.Remove(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// discard everything about the login, including "remember me", further
+	// use of the session manager starts a fresh session with a new token
+	err = app.sessionManager.Destroy(r.Context())
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	// remove user ID from session, which equates to logged out
-	app.sessionManager.Remove(r.Context(), sessionUserIdKey)
 	app.sessionManager.Put(r.Context(), "flash", "You've been logged out")
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
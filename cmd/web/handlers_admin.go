@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+)
+
+const adminSearchLimit = 50
+
+// adminDashboard summarizes users and snippets for admins.
+func (app *application) adminDashboard(w http.ResponseWriter, r *http.Request) {
+	userStats, err := app.users.Stats()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippetStats, err := app.snippets.Stats()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	recent, err := app.users.Search("", 5)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.UserStats = userStats
+	data.SnippetStats = snippetStats
+	data.Users = recent
+
+	app.render(w, http.StatusOK, "admin.tmpl", data)
+}
+
+// adminUsers lists accounts matching the optional "q" query parameter so an
+// admin can manage their roles and status.
+func (app *application) adminUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	users, err := app.users.Search(query, adminSearchLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Users = users
+	data.Roles = models.Roles
+	data.Query = query
+
+	app.render(w, http.StatusOK, "admin_users.tmpl", data)
+}
+
+// adminUserTarget parses the user ID from the URL of an admin action and
+// confirms the user exists and isn't the admin performing the action.
+func (app *application) adminUserTarget(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return 0, false
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	// prevent admins from locking themselves out
+	if id == app.authenticatedUserID(r) {
+		app.sessionManager.Put(r.Context(), "flash", "You cannot change your own account here.")
+		app.redirectToAdminSearch(w, r, "/admin/users")
+		return 0, false
+	}
+
+	_, err = app.users.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return 0, false
+	}
+
+	return id, true
+}
+
+// redirectToAdminSearch returns to an admin table, preserving the search
+// query that was submitted with the form.
+func (app *application) redirectToAdminSearch(w http.ResponseWriter, r *http.Request, path string) {
+	if q := r.PostForm.Get("q"); q != "" {
+		path = fmt.Sprintf("%s?q=%s", path, url.QueryEscape(q))
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *application) adminUserRolePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserTarget(w, r)
+	if !ok {
+		return
+	}
+
+	role := models.Role(r.PostForm.Get("role"))
+	if !role.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.users.RoleUpdate(id, role)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Role updated.")
+
+	app.redirectToAdminSearch(w, r, "/admin/users")
+}
+
+// adminUserDisablePost disables or re-enables an account.  Disabling also
+// logs the user out everywhere.
+func (app *application) adminUserDisablePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserTarget(w, r)
+	if !ok {
+		return
+	}
+
+	disabled := r.PostForm.Get("disabled") == "true"
+
+	err := app.users.SetDisabled(id, disabled)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if disabled {
+		err = app.userSessions.RevokeOthers(id, "")
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", "User disabled.")
+	} else {
+		app.sessionManager.Put(r.Context(), "flash", "User enabled.")
+	}
+
+	app.redirectToAdminSearch(w, r, "/admin/users")
+}
+
+// adminUserDeletePost soft-deletes an account, or restores it while it is
+// still within the grace period before being purged.
+func (app *application) adminUserDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminUserTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("deleted") == "false" {
+		err := app.users.Restore(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", "User restored.")
+		app.redirectToAdminSearch(w, r, "/admin/users")
+		return
+	}
+
+	err := app.users.SoftDelete(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.userSessions.RevokeOthers(id, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("User deleted.  Their account will be purged after %s.", app.purgeGrace))
+
+	app.redirectToAdminSearch(w, r, "/admin/users")
+}
+
+// adminSnippets lists snippets matching the optional "q" query parameter,
+// including expired and hidden ones.
+func (app *application) adminSnippets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+
+	snippets, err := app.snippets.Search(query, adminSearchLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Query = query
+
+	app.render(w, http.StatusOK, "admin_snippets.tmpl", data)
+}
+
+// adminSnippetTarget parses the snippet ID from the URL of an admin action.
+func (app *application) adminSnippetTarget(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return 0, false
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) adminSnippetHidePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminSnippetTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.SetHidden(id, r.PostForm.Get("hidden") == "true")
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet updated.")
+
+	app.redirectToAdminSearch(w, r, "/admin/snippets")
+}
+
+func (app *application) adminSnippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.adminSnippetTarget(w, r)
+	if !ok {
+		return
+	}
+
+	owner, err := app.snippets.Delete(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.queueWebhookEvent(owner, models.EventSnippetDeleted, deletedSnippet{ID: id})
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted.")
+
+	app.redirectToAdminSearch(w, r, "/admin/snippets")
+}
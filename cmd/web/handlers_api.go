@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+const apiPageSize = 50
+
+// apiSnippetInput is the request body for creating a snippet.  Omitted
+// expires and visibility default to a year and public.
+type apiSnippetInput struct {
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Expires    int      `json:"expires"` // days: 1, 7 or 365
+	Visibility string   `json:"visibility"`
+	TeamID     int      `json:"team_id"`
+	Tags       []string `json:"tags"`
+}
+
+// apiSnippetUpdate is the request body for replacing the editable fields
+// of a snippet.
+type apiSnippetUpdate struct {
+	Title   string   `json:"title"`
+	Content string   `json:"content"`
+	Tags    []string `json:"tags"`
+}
+
+// apiRequestSnippet loads the snippet named in the URL, responding with a
+// JSON 404 if it doesn't exist or the current user may not read it.
+func (app *application) apiRequestSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, err := app.lookupSnippet(r)
+	if err != nil {
+		app.apiModelError(w, err)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// apiSnippetList returns a page of the current user's snippets, accepting
+// the same filters as /snippet/mine.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	filter := snippetFilterParams(r.URL.Query())
+	page := pageParam(r)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := app.snippets.Filter(app.authenticatedUserID(r), filter, apiPageSize+1, (page-1)*apiPageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	var nextPage *int
+	if len(snippets) > apiPageSize {
+		snippets = snippets[:apiPageSize]
+		if page < maxPage {
+			next := page + 1
+			nextPage = &next
+		}
+	}
+
+	list := make([]apiSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		list = append(list, newAPISnippet(s))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"snippets":  list,
+		"page":      page,
+		"next_page": nextPage,
+	})
+}
+
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiRequestSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+// apiSnippetCreate applies the same validation rules as snippetCreatePost.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Expires == 0 {
+		input.Expires = 365
+	}
+	if input.Visibility == "" {
+		input.Visibility = string(models.VisibilityPublic)
+	}
+
+	form := snippetCreateForm{
+		Title:      input.Title,
+		Content:    input.Content,
+		Expires:    input.Expires,
+		Visibility: input.Visibility,
+		Tags:       strings.Join(input.Tags, ","),
+		Team:       input.TeamID,
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	teams, err := app.teams.ForUser(userID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	tags := form.check(teams)
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	id, err := app.snippets.Insert(userID, form.Title, form.Content, form.Expires, models.Visibility(form.Visibility), form.Team, tags)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.queueSnippetEvent(models.EventSnippetCreated, snippet)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+// apiSnippetUpdate replaces the title, content and tags of a snippet the
+// current user may edit, with the same validation as snippetEditPost.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiRequestSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	canEdit, err := app.canEditSnippet(r, snippet)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	if !canEdit {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	var input apiSnippetUpdate
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.apiErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	form := snippetEditForm{
+		Title:   input.Title,
+		Content: input.Content,
+		Tags:    strings.Join(input.Tags, ","),
+	}
+
+	tags := form.check()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content, tags)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippet, err = app.snippets.Get(snippet.ID)
+	if err != nil {
+		app.apiModelError(w, err)
+		return
+	}
+
+	app.queueSnippetEvent(models.EventSnippetUpdated, snippet)
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+// apiSnippetDelete permanently removes a snippet owned by the current user.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiRequestSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.isSnippetOwner(r, snippet) {
+		app.apiClientError(w, http.StatusForbidden)
+		return
+	}
+
+	owner, err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	app.queueWebhookEvent(owner, models.EventSnippetDeleted, deletedSnippet{ID: snippet.ID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
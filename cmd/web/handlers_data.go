@@ -0,0 +1,229 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	deletionPolicyDelete    = "delete"
+	deletionPolicyAnonymize = "anonymize"
+)
+
+// accountConfirmForm asks for the current password before a sensitive action,
+// unless the user has only just logged in.
+type accountConfirmForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type exportProfile struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Handle   string    `json:"handle"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+	Exported time.Time `json:"exported"`
+}
+
+type exportSnippet struct {
+	ID         int               `json:"id"`
+	Title      string            `json:"title"`
+	Content    string            `json:"content"`
+	Created    time.Time         `json:"created"`
+	Expires    time.Time         `json:"expires"`
+	Visibility models.Visibility `json:"visibility"`
+	Hidden     bool              `json:"hidden"`
+	Tags       []string          `json:"tags"`
+}
+
+// confirmIdentity decodes an accountConfirmForm and checks the password,
+// re-rendering page with an error if it is wrong.  Users who logged in
+// within recentLoginWindow need not give a password, which is how accounts
+// that only log in with a passkey or an identity provider confirm.
+func (app *application) confirmIdentity(w http.ResponseWriter, r *http.Request, page string) bool {
+	var form accountConfirmForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return false
+	}
+
+	if app.recentLogin(r) {
+		return true
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() {
+		err = app.users.CheckPassword(app.authenticatedUserID(r), form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return false
+			}
+
+			form.AddFieldError("password", "Password is incorrect")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.DeletionPolicy = app.deletionPolicy
+		app.render(w, http.StatusUnprocessableEntity, page, data)
+		return false
+	}
+
+	return true
+}
+
+func (app *application) accountExport(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountConfirmForm{}
+	data.RecentLogin = app.recentLogin(r)
+	app.render(w, http.StatusOK, "export.tmpl", data)
+}
+
+// accountExportPost streams a ZIP archive of the user's profile and all of
+// their snippets as JSON, plus each snippet as a plain text file.
+func (app *application) accountExportPost(w http.ResponseWriter, r *http.Request) {
+	if !app.confirmIdentity(w, r, "export.tmpl") {
+		return
+	}
+
+	user, err := app.users.Get(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	snippets, err := app.snippets.ForUser(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	profile := exportProfile{
+		ID:       user.ID,
+		Name:     user.Name,
+		Handle:   user.Handle,
+		Email:    user.Email,
+		Created:  user.Created,
+		Exported: time.Now().UTC(),
+	}
+
+	exported := make([]exportSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		exported = append(exported, exportSnippet{
+			ID:         s.ID,
+			Title:      s.Title,
+			Content:    s.Content,
+			Created:    s.Created,
+			Expires:    s.Expires,
+			Visibility: s.Visibility,
+			Hidden:     s.Hidden,
+			Tags:       s.Tags,
+		})
+	}
+
+	filename := fmt.Sprintf("snippetbox-export-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	zw := zip.NewWriter(w)
+
+	err = writeZipJSON(zw, "profile.json", profile)
+	if err == nil {
+		err = writeZipJSON(zw, "snippets.json", exported)
+	}
+	for i := 0; err == nil && i < len(exported); i++ {
+		var f io.Writer
+		f, err = zw.Create(fmt.Sprintf("snippets/%d.txt", exported[i].ID))
+		if err == nil {
+			_, err = f.Write([]byte(exported[i].Content))
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+
+	// headers have already been sent, so the best we can do is log
+	if err != nil {
+		app.errorLog.Printf("writing export for user %d: %v", user.ID, err)
+	}
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (app *application) accountDelete(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = accountConfirmForm{}
+	data.DeletionPolicy = app.deletionPolicy
+	data.RecentLogin = app.recentLogin(r)
+	app.render(w, http.StatusOK, "delete.tmpl", data)
+}
+
+// accountReauthPost sends the user to log in again, by whichever method they
+// use, returning them to the export or delete page afterwards.
+func (app *application) accountReauthPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	next := r.PostForm.Get("next")
+	if next != "/account/export" && next != "/account/delete" {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), sessionLoginRedirectKey, next)
+	app.sessionManager.Put(r.Context(), "flash", "Please log in again to continue.")
+
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// accountDeletePost permanently deletes the current user, destroying all of
+// their sessions.  Snippets are deleted or anonymized per -deletion-policy.
+func (app *application) accountDeletePost(w http.ResponseWriter, r *http.Request) {
+	if !app.confirmIdentity(w, r, "delete.tmpl") {
+		return
+	}
+
+	err := app.users.DeletePermanently(app.authenticatedUserID(r), app.deletionPolicy == deletionPolicyAnonymize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// the session row is already gone, make sure the cookie goes too
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account has been deleted.")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
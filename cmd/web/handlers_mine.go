@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const minePageSize = 50
+
+type snippetBulkForm struct {
+	IDs                 []int  `form:"id"`
+	Action              string `form:"action"`
+	Days                int    `form:"days"`
+	Query               string `form:"q"` // listing filters to return to
+	validator.Validator `form:"-"`
+}
+
+// snippetFilterParams reads the listing filters from the query string,
+// ignoring values that aren't recognized.
+func snippetFilterParams(q url.Values) models.SnippetFilter {
+	f := models.SnippetFilter{
+		Tag:        q.Get("tag"),
+		Visibility: models.Visibility(q.Get("visibility")),
+		Expiry:     q.Get("expiry"),
+		Sort:       q.Get("sort"),
+	}
+
+	if !f.Visibility.Valid() {
+		f.Visibility = ""
+	}
+	if f.Expiry != models.ExpiryActive && f.Expiry != models.ExpiryExpired {
+		f.Expiry = ""
+	}
+
+	return f
+}
+
+// snippetMine lists all of the current user's snippets, including expired,
+// private and hidden ones.
+func (app *application) snippetMine(w http.ResponseWriter, r *http.Request) {
+	userID := app.authenticatedUserID(r)
+	filter := snippetFilterParams(r.URL.Query())
+	page := pageParam(r)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := app.snippets.Filter(userID, filter, minePageSize+1, (page-1)*minePageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	more := len(snippets) > minePageSize
+	if more {
+		snippets = snippets[:minePageSize]
+	}
+
+	tags, err := app.snippets.Tags(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Filter = filter
+	data.Tags = tags
+	data.Sorts = models.SnippetSorts
+	data.Visibilities = models.Visibilities
+	data.Query = r.URL.RawQuery
+	data.Pagination = newPagination(r, page, more)
+
+	app.render(w, http.StatusOK, "mine.tmpl", data)
+}
+
+// snippetMineBulkPost extends or deletes the selected snippets.  Only
+// snippets owned by the current user are affected.
+func (app *application) snippetMineBulkPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetBulkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	var flash string
+	var deleted []int
+	switch {
+	case len(form.IDs) == 0:
+		flash = "No snippets were selected."
+	case form.Action == "extend" && validator.PermittedInt(form.Days, 1, 7, 365):
+		var n int
+		n, err = app.snippets.Extend(userID, form.IDs, form.Days)
+		flash = fmt.Sprintf("Extended %d snippet(s) by %d day(s).", n, form.Days)
+	case form.Action == "delete":
+		deleted, err = app.snippets.DeleteOwned(userID, form.IDs)
+		flash = fmt.Sprintf("Deleted %d snippet(s).", len(deleted))
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	for _, id := range deleted {
+		app.queueWebhookEvent(userID, models.EventSnippetDeleted, deletedSnippet{ID: id})
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", flash)
+
+	// return to the same filtered listing
+	path := "/snippet/mine"
+	if q, err := url.ParseQuery(form.Query); err == nil && len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
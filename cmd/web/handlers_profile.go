@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+)
+
+const profilePageSize = 20
+
+// maxPage bounds the page query parameter, so that multiplying it by a page
+// size can't overflow the OFFSET of a query.
+const maxPage = 10000
+
+// pagination describes the links around a page of results.  PrevURL and
+// NextURL are empty when there is no such page.
+type pagination struct {
+	Page    int
+	PrevURL string
+	NextURL string
+}
+
+// pageParam returns the 1-based page number from the query string,
+// defaulting to the first page and capped at maxPage.
+func pageParam(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		return 1
+	}
+
+	return min(page, maxPage)
+}
+
+// newPagination builds the links for page, given whether more results
+// follow it, keeping any other query parameters of the request.
+func newPagination(r *http.Request, page int, more bool) *pagination {
+	pageURL := func(n int) string {
+		q := r.URL.Query()
+		q.Set("page", strconv.Itoa(n))
+		return r.URL.Path + "?" + q.Encode()
+	}
+
+	p := &pagination{Page: page}
+	if page > 1 {
+		p.PrevURL = pageURL(page - 1)
+	}
+	if more && page < maxPage {
+		p.NextURL = pageURL(page + 1)
+	}
+
+	return p
+}
+
+// userProfile shows a user's display name, join date and public snippets.
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	user, err := app.users.GetByHandle(params.ByName("handle"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+			return
+		}
+
+		app.serverError(w, err)
+		return
+	}
+
+	page := pageParam(r)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := app.snippets.PublicForUser(user.ID, profilePageSize+1, (page-1)*profilePageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	more := len(snippets) > profilePageSize
+	if more {
+		snippets = snippets[:profilePageSize]
+	}
+
+	data := app.newTemplateData(r)
+	data.Author = user
+	data.Snippets = snippets
+	data.Pagination = newPagination(r, page, more)
+
+	app.render(w, http.StatusOK, "profile.tmpl", data)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const sharedPageSize = 20
+
+type snippetShareForm struct {
+	Email               string `form:"email"`
+	Permission          string `form:"permission"`
+	validator.Validator `form:"-"`
+}
+
+// requestOwnedSnippet is like requestSnippet but responds with 403 unless
+// the current user owns the snippet.
+func (app *application) requestOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.requestSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !app.isSnippetOwner(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// snippetSharePost grants another user read or edit access to a snippet.
+func (app *application) snippetSharePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetShareForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRegex), "email", "This must be a valid email address")
+	form.CheckField(models.SharePermission(form.Permission).Valid(), "permission", "This field must be read or edit")
+
+	if user := app.authenticatedUser(r); user != nil && form.Email == user.Email {
+		form.AddFieldError("email", "You already own this snippet")
+	}
+
+	if !form.Valid() {
+		data, err := app.snippetViewData(r, snippet)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "view.tmpl", data)
+		return
+	}
+
+	// respond the same whether or not the address has an account, so the
+	// form can't be used to find out who is registered
+	err = app.shares.Grant(snippet.ID, form.Email, models.SharePermission(form.Permission))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("If %s has a Snippetbox account, they can now %s this snippet.", form.Email, form.Permission))
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetUnsharePost revokes a user's access to a snippet.
+func (app *application) snippetUnsharePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user"))
+	if err != nil || userID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.shares.Revoke(snippet.ID, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Access revoked.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
+
+// snippetShared lists the snippets other users have shared with the
+// current user.
+func (app *application) snippetShared(w http.ResponseWriter, r *http.Request) {
+	page := pageParam(r)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := app.shares.SharedWith(app.authenticatedUserID(r), sharedPageSize+1, (page-1)*sharedPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	more := len(snippets) > sharedPageSize
+	if more {
+		snippets = snippets[:sharedPageSize]
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = newPagination(r, page, more)
+
+	app.render(w, http.StatusOK, "shared.tmpl", data)
+}
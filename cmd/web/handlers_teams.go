@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	teamInvitationTTL = 7 * 24 * time.Hour
+	teamPageSize      = 20
+)
+
+type teamCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+type teamInviteForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+// teamIncludes reports whether id is one of teams.
+func teamIncludes(teams []*models.Team, id int) bool {
+	for _, t := range teams {
+		if t.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestTeam loads the team named in the URL as seen by the current user,
+// responding with 404 if they are not a member.
+func (app *application) requestTeam(w http.ResponseWriter, r *http.Request) (*models.Team, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	team, err := app.teams.Get(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	return team, true
+}
+
+// requestOwnedTeam is like requestTeam but responds with 403 unless the
+// current user owns the team.
+func (app *application) requestOwnedTeam(w http.ResponseWriter, r *http.Request) (*models.Team, bool) {
+	team, ok := app.requestTeam(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if team.Role != models.TeamRoleOwner {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return team, true
+}
+
+func (app *application) renderTeams(w http.ResponseWriter, r *http.Request, status int, form teamCreateForm) {
+	teams, err := app.teams.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Teams = teams
+	data.Form = form
+
+	app.render(w, status, "teams.tmpl", data)
+}
+
+// teamList shows the teams the current user belongs to.
+func (app *application) teamList(w http.ResponseWriter, r *http.Request) {
+	app.renderTeams(w, r, http.StatusOK, teamCreateForm{})
+}
+
+func (app *application) teamCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form teamCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		app.renderTeams(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	id, err := app.teams.Insert(form.Name, app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Team created.")
+
+	http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", id), http.StatusSeeOther)
+}
+
+func (app *application) renderTeam(w http.ResponseWriter, r *http.Request, status int, team *models.Team, form teamInviteForm) {
+	members, err := app.teams.Members(team.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	page := pageParam(r)
+
+	// fetch one extra to find out whether there is a next page
+	snippets, err := app.snippets.ForTeam(team.ID, teamPageSize+1, (page-1)*teamPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	more := len(snippets) > teamPageSize
+	if more {
+		snippets = snippets[:teamPageSize]
+	}
+
+	data := app.newTemplateData(r)
+	data.Team = team
+	data.TeamMembers = members
+	data.TeamRoles = models.TeamRoles
+	data.Snippets = snippets
+	data.Pagination = newPagination(r, page, more)
+	data.Form = form
+
+	app.render(w, status, "team.tmpl", data)
+}
+
+// teamView shows a team's members and the snippets shared with it.
+func (app *application) teamView(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestTeam(w, r)
+	if !ok {
+		return
+	}
+
+	app.renderTeam(w, r, http.StatusOK, team, teamInviteForm{})
+}
+
+// teamInvitePost emails a link inviting someone to join a team.
+func (app *application) teamInvitePost(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestOwnedTeam(w, r)
+	if !ok {
+		return
+	}
+
+	var form teamInviteForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRegex), "email", "This must be a valid email address")
+
+	if !form.Valid() {
+		app.renderTeam(w, r, http.StatusUnprocessableEntity, team, form)
+		return
+	}
+
+	token, err := app.teams.Invite(team.ID, form.Email, app.authenticatedUserID(r), teamInvitationTTL)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/teams/join?token=%s", app.origin, token)
+	body := fmt.Sprintf("You have been invited to join the team %q on Snippetbox.\n\n"+
+		"Log in or sign up with this email address, then follow this link within 7 days to accept:\n\n%s\n", team.Name, link)
+
+	err = app.mailer.Send(form.Email, "You're invited to join a Snippetbox team", body)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Invitation sent to %s.", form.Email))
+
+	http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", team.ID), http.StatusSeeOther)
+}
+
+// teamJoin accepts an invitation for the current user.
+func (app *application) teamJoin(w http.ResponseWriter, r *http.Request) {
+	id, err := app.teams.AcceptInvitation(r.URL.Query().Get("token"), app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.sessionManager.Put(r.Context(), "flash", "That invitation is invalid, has expired, or was sent to a different email address.")
+			http.Redirect(w, r, "/teams", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Welcome to the team!")
+
+	http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", id), http.StatusSeeOther)
+}
+
+// memberParam returns the user ID submitted in the "user" form field.
+func (app *application) memberParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	userID, err := strconv.Atoi(r.PostForm.Get("user"))
+	if err != nil || userID < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func (app *application) teamMemberRolePost(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestOwnedTeam(w, r)
+	if !ok {
+		return
+	}
+
+	userID, ok := app.memberParam(w, r)
+	if !ok {
+		return
+	}
+
+	role := models.TeamRole(r.PostForm.Get("role"))
+	if !role.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.teams.SetMemberRole(team.ID, userID, role)
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.sessionManager.Put(r.Context(), "flash", "That user is not a member of this team.")
+	case errors.Is(err, models.ErrLastOwner):
+		app.sessionManager.Put(r.Context(), "flash", "A team must keep at least one owner.")
+	case err != nil:
+		app.serverError(w, err)
+		return
+	default:
+		app.sessionManager.Put(r.Context(), "flash", "Role updated.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", team.ID), http.StatusSeeOther)
+}
+
+func (app *application) teamMemberRemovePost(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestOwnedTeam(w, r)
+	if !ok {
+		return
+	}
+
+	userID, ok := app.memberParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.teams.RemoveMember(team.ID, userID)
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.sessionManager.Put(r.Context(), "flash", "That user is not a member of this team.")
+	case errors.Is(err, models.ErrLastOwner):
+		app.sessionManager.Put(r.Context(), "flash", "A team must keep at least one owner.")
+	case err != nil:
+		app.serverError(w, err)
+		return
+	default:
+		app.sessionManager.Put(r.Context(), "flash", "Member removed.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", team.ID), http.StatusSeeOther)
+}
+
+// teamLeavePost removes the current user from a team.
+func (app *application) teamLeavePost(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestTeam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.teams.RemoveMember(team.ID, app.authenticatedUserID(r))
+	if err != nil {
+		if !errors.Is(err, models.ErrLastOwner) {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sessionManager.Put(r.Context(), "flash", "Make someone else an owner before leaving, or delete the team.")
+		http.Redirect(w, r, fmt.Sprintf("/teams/view/%d", team.ID), http.StatusSeeOther)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("You have left %s.", team.Name))
+
+	http.Redirect(w, r, "/teams", http.StatusSeeOther)
+}
+
+func (app *application) teamDeletePost(w http.ResponseWriter, r *http.Request) {
+	team, ok := app.requestOwnedTeam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.teams.Delete(team.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Team %s deleted.", team.Name))
+
+	http.Redirect(w, r, "/teams", http.StatusSeeOther)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestUserLogout(t *testing.T) {
+	app := newTestApplication(t)
+
+	err := app.users.Insert("Alice", "alice", "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := newTestServer(t, app.routes())
+	ts.logIn(t, app, 1)
+
+	// a remembered login waiting to return to the export page
+	loggedIn := ts.session(t, app.sessionManager)
+	app.sessionManager.Put(loggedIn, sessionRememberMeKey, true)
+	app.sessionManager.Put(loggedIn, sessionLoginRedirectKey, "/account/export")
+	app.sessionManager.RememberMe(loggedIn, true)
+	oldToken, _, err := app.sessionManager.Commit(loggedIn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().PostForm(ts.URL+"/user/logout", url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+
+	if rs.StatusCode != http.StatusSeeOther {
+		t.Fatalf("got status %d; want %d", rs.StatusCode, http.StatusSeeOther)
+	}
+
+	for _, c := range rs.Cookies() {
+		if c.Name == app.sessionManager.Cookie.Name {
+			if c.Value == oldToken {
+				t.Error("session token was not changed")
+			}
+			if !c.Expires.IsZero() || c.MaxAge > 0 {
+				t.Errorf("session cookie persists until %v; want a browser session cookie", c.Expires)
+			}
+		}
+	}
+
+	session := ts.session(t, app.sessionManager)
+	if got := app.sessionManager.Keys(session); len(got) != 1 || got[0] != "flash" {
+		t.Errorf("session holds %q after logout; want only the flash", got)
+	}
+
+	old, err := app.sessionManager.Load(context.Background(), oldToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.sessionManager.Exists(old, sessionUserIdKey) {
+		t.Error("old session is still logged in")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+type apiTokenForm struct {
+	Name                string   `form:"name"`
+	Scopes              []string `form:"scope"`
+	Days                int      `form:"days"`
+	validator.Validator `form:"-"`
+}
+
+// HasScope reports whether the form has scope checked.
+func (form apiTokenForm) HasScope(scope models.APIScope) bool {
+	for _, s := range form.Scopes {
+		if models.APIScope(s) == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (app *application) renderAPITokens(w http.ResponseWriter, r *http.Request, status int, form apiTokenForm, token string) {
+	tokens, err := app.apiTokens.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.APITokens = tokens
+	data.APIScopes = models.APIScopes
+	data.NewAPIToken = token
+	data.Form = form
+
+	app.render(w, status, "tokens.tmpl", data)
+}
+
+// accountAPITokens lists the current user's personal API tokens.
+func (app *application) accountAPITokens(w http.ResponseWriter, r *http.Request) {
+	form := apiTokenForm{Scopes: []string{string(models.ScopeSnippetsRead)}, Days: 30}
+
+	app.renderAPITokens(w, r, http.StatusOK, form, "")
+}
+
+// accountAPITokenCreatePost creates a token and shows it once; only its hash
+// is stored so it can't be displayed again.
+func (app *application) accountAPITokenCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form apiTokenForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(len(form.Scopes) > 0, "scope", "Select at least one scope")
+	scopes := make([]models.APIScope, 0, len(form.Scopes))
+	for _, s := range form.Scopes {
+		form.CheckField(models.APIScope(s).Valid(), "scope", "Unknown scope")
+		scopes = append(scopes, models.APIScope(s))
+	}
+	form.CheckField(validator.PermittedInt(form.Days, 0, 7, 30, 90, 365), "days", "This field must equal 7, 30, 90, 365 or never")
+
+	if !form.Valid() {
+		app.renderAPITokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	ttl := time.Duration(form.Days) * 24 * time.Hour
+
+	token, err := app.apiTokens.Insert(app.authenticatedUserID(r), form.Name, scopes, ttl)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderAPITokens(w, r, http.StatusOK, apiTokenForm{Scopes: form.Scopes, Days: form.Days}, token)
+}
+
+func (app *application) accountAPITokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.apiTokens.Revoke(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The API token has been revoked.")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
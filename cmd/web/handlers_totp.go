@@ -0,0 +1,313 @@
+package main
+
+import (
+	"errors"
+	"image/png"
+	"net/http"
+	"strings"
+
+	"github.com/pquerna/otp/totp"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	totpIssuer           = "Snippetbox"
+	totpMaxLoginAttempts = 5
+)
+
+type totpForm struct {
+	Code                string `form:"code"`
+	validator.Validator `form:"-"`
+}
+
+// accountTOTP shows the two-factor status of the current user, along with
+// the pending key if they have started enrolling.
+func (app *application) accountTOTP(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = totpForm{}
+
+	key, enabled, err := app.totp.Key(app.authenticatedUserID(r))
+	if err != nil && !errors.Is(err, models.ErrNoRecord) {
+		app.serverError(w, err)
+		return
+	}
+
+	if key != nil && !enabled {
+		data.TOTPURI = key.String()
+		data.TOTPSecret = key.Secret()
+	}
+
+	data.TOTPEnabled = enabled
+
+	app.render(w, http.StatusOK, "totp.tmpl", data)
+}
+
+// accountTOTPSetupPost generates a new pending key for enrollment, replacing
+// any earlier one that was never confirmed.
+func (app *application) accountTOTPSetupPost(w http.ResponseWriter, r *http.Request) {
+	id := app.authenticatedUserID(r)
+
+	enabled, err := app.totp.Enabled(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !enabled {
+		user, err := app.users.Get(id)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      totpIssuer,
+			AccountName: user.Email,
+		})
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		err = app.totp.SetPending(id, key)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/account/totp", http.StatusSeeOther)
+}
+
+// accountTOTPQRCode renders the pending key of the current user as a QR code
+// image for scanning by an authenticator app.
+func (app *application) accountTOTPQRCode(w http.ResponseWriter, r *http.Request) {
+	key, enabled, err := app.totp.Key(app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+			return
+		}
+
+		app.serverError(w, err)
+		return
+	}
+
+	// never reveal the key once enrollment is complete
+	if enabled {
+		app.notFound(w)
+		return
+	}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	png.Encode(w, img)
+}
+
+// accountTOTPEnablePost verifies the first code from the authenticator app
+// and, if correct, enables 2FA and displays the recovery codes once.
+func (app *application) accountTOTPEnablePost(w http.ResponseWriter, r *http.Request) {
+	var form totpForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+
+	key, enabled, err := app.totp.Key(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.Redirect(w, r, "/account/totp", http.StatusSeeOther)
+			return
+		}
+
+		app.serverError(w, err)
+		return
+	}
+
+	if enabled {
+		http.Redirect(w, r, "/account/totp", http.StatusSeeOther)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+	if form.Valid() {
+		form.CheckField(totp.Validate(strings.TrimSpace(form.Code), key.Secret()), "code", "This code is not valid")
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.TOTPURI = key.String()
+		data.TOTPSecret = key.Secret()
+		app.render(w, http.StatusUnprocessableEntity, "totp.tmpl", data)
+		return
+	}
+
+	codes, err := app.totp.Enable(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = totpForm{}
+	data.TOTPEnabled = true
+	data.RecoveryCodes = codes
+	data.Flash = "Two-factor authentication is now enabled."
+
+	app.render(w, http.StatusOK, "totp.tmpl", data)
+}
+
+// accountTOTPDisablePost turns off 2FA after confirming a current code.
+func (app *application) accountTOTPDisablePost(w http.ResponseWriter, r *http.Request) {
+	var form totpForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	id := app.authenticatedUserID(r)
+
+	ok, err := app.verifyTOTP(id, form.Code)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if !ok {
+		form.AddFieldError("code", "This code is not valid")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		data.TOTPEnabled = true
+		app.render(w, http.StatusUnprocessableEntity, "totp.tmpl", data)
+		return
+	}
+
+	err = app.totp.Disable(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Two-factor authentication has been disabled.")
+
+	http.Redirect(w, r, "/account/totp", http.StatusSeeOther)
+}
+
+// userLoginTOTP displays the second login step for users with 2FA enabled.
+func (app *application) userLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	if !app.sessionManager.Exists(r.Context(), sessionPendingUserIdKey) {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = totpForm{}
+	app.render(w, http.StatusOK, "login_totp.tmpl", data)
+}
+
+func (app *application) userLoginTOTPPost(w http.ResponseWriter, r *http.Request) {
+	id := app.sessionManager.GetInt(r.Context(), sessionPendingUserIdKey)
+	if id == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	var form totpForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	ok := false
+	if form.Valid() {
+		ok, err = app.verifyTOTP(id, form.Code)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	if !ok {
+		// limit guessing by abandoning the login after repeated failures
+		attempts := app.sessionManager.GetInt(r.Context(), sessionPendingAttemptsKey) + 1
+		if attempts >= totpMaxLoginAttempts {
+			app.sessionManager.Remove(r.Context(), sessionPendingUserIdKey)
+			app.sessionManager.Remove(r.Context(), sessionPendingAttemptsKey)
+			app.sessionManager.Remove(r.Context(), sessionPendingRememberKey)
+			app.sessionManager.Put(r.Context(), "flash", "Too many invalid codes.  Please log in again.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+		app.sessionManager.Put(r.Context(), sessionPendingAttemptsKey, attempts)
+
+		form.AddNonFieldError("Authentication code is incorrect")
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "login_totp.tmpl", data)
+		return
+	}
+
+	remember := app.sessionManager.PopBool(r.Context(), sessionPendingRememberKey)
+	app.sessionManager.Remove(r.Context(), sessionPendingUserIdKey)
+	app.sessionManager.Remove(r.Context(), sessionPendingAttemptsKey)
+
+	err = app.logIn(r, id, remember)
+	if err != nil {
+		if errors.Is(err, models.ErrAccountDisabled) {
+			app.sessionManager.Put(r.Context(), "flash", "Your account has been disabled.")
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, app.loginRedirect(r), http.StatusSeeOther)
+}
+
+// verifyTOTP checks a code against the enabled key of a user, falling back to
+// consuming one of their recovery codes.
+func (app *application) verifyTOTP(userID int, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	key, enabled, err := app.totp.Key(userID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if !enabled {
+		return false, nil
+	}
+
+	if totp.Validate(code, key.Secret()) {
+		return true, nil
+	}
+
+	return app.totp.UseRecoveryCode(userID, code)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	sessionWebAuthnRegistrationKey = "webauthnRegistration"
+	sessionWebAuthnLoginKey        = "webauthnLogin"
+)
+
+// webAuthnUser adapts a models.User and its passkeys to the webauthn.User
+// interface.  The user handle is the decimal user ID.
+type webAuthnUser struct {
+	user        *models.User
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(strconv.Itoa(u.user.ID))
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// loadWebAuthnUser fetches a user together with their registered passkeys.
+func (app *application) loadWebAuthnUser(id int) (*webAuthnUser, error) {
+	user, err := app.users.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := app.webauthnCredentials.ForUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &webAuthnUser{user: user}
+	for _, c := range creds {
+		u.credentials = append(u.credentials, c.Credential)
+	}
+
+	return u, nil
+}
+
+// putWebAuthnSession stores ceremony state in the user's session between the
+// begin and finish requests.
+func (app *application) putWebAuthnSession(r *http.Request, key string, sd *webauthn.SessionData) error {
+	b, err := json.Marshal(sd)
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), key, b)
+	return nil
+}
+
+// popWebAuthnSession retrieves and removes ceremony state, so that each
+// challenge can only be answered once.
+func (app *application) popWebAuthnSession(r *http.Request, key string) (*webauthn.SessionData, error) {
+	b := app.sessionManager.PopBytes(r.Context(), key)
+	if b == nil {
+		return nil, errors.New("no webauthn ceremony in progress")
+	}
+
+	var sd webauthn.SessionData
+	err := json.Unmarshal(b, &sd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sd, nil
+}
+
+// accountPasskeys lists the passkeys of the current user.
+func (app *application) accountPasskeys(w http.ResponseWriter, r *http.Request) {
+	creds, err := app.webauthnCredentials.ForUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Passkeys = creds
+	app.render(w, http.StatusOK, "passkeys.tmpl", data)
+}
+
+func (app *application) accountPasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	user, err := app.loadWebAuthnUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// don't register the same authenticator twice
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, c := range user.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+
+	creation, sd, err := app.webAuthn.BeginRegistration(user,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(exclusions),
+	)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.putWebAuthnSession(r, sessionWebAuthnRegistrationKey, sd)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, creation)
+}
+
+func (app *application) accountPasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if !validator.NotBlank(name) || !validator.MaxChars(name, 100) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	sd, err := app.popWebAuthnSession(r, sessionWebAuthnRegistrationKey)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user, err := app.loadWebAuthnUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	cred, err := app.webAuthn.FinishRegistration(user, *sd, r)
+	if err != nil {
+		app.infoLog.Printf("passkey registration failed: %v", err)
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err = app.webauthnCredentials.Insert(user.user.ID, name, cred)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateCredential) {
+			app.clientError(w, http.StatusConflict)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been registered.")
+
+	app.writeJSON(w, http.StatusOK, map[string]string{"redirect": "/account/passkeys"})
+}
+
+func (app *application) accountPasskeyDeletePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.webauthnCredentials.Delete(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your passkey has been removed.")
+
+	http.Redirect(w, r, "/account/passkeys", http.StatusSeeOther)
+}
+
+// userLoginPasskeyBegin starts a discoverable login, letting the
+// authenticator choose the account so no email address is needed.
+func (app *application) userLoginPasskeyBegin(w http.ResponseWriter, r *http.Request) {
+	assertion, sd, err := app.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.putWebAuthnSession(r, sessionWebAuthnLoginKey, sd)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, assertion)
+}
+
+func (app *application) userLoginPasskeyFinish(w http.ResponseWriter, r *http.Request) {
+	sd, err := app.popWebAuthnSession(r, sessionWebAuthnLoginKey)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		id, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		return app.loadWebAuthnUser(id)
+	}
+
+	user, cred, err := app.webAuthn.FinishPasskeyLogin(handler, *sd, r)
+	if err != nil {
+		app.infoLog.Printf("passkey login failed: %v", err)
+		app.clientError(w, http.StatusUnauthorized)
+		return
+	}
+
+	err = app.webauthnCredentials.Touch(cred)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.logIn(r, user.(*webAuthnUser).user.ID, false)
+	if err != nil {
+		if errors.Is(err, models.ErrAccountDisabled) {
+			app.clientError(w, http.StatusForbidden)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]string{"redirect": app.loginRedirect(r)})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+const (
+	maxWebhooks          = 10
+	webhookDeliveryCount = 20
+)
+
+type webhookForm struct {
+	URL                 string   `form:"url"`
+	Events              []string `form:"event"`
+	validator.Validator `form:"-"`
+}
+
+// HasEvent reports whether the form has event checked.
+func (form webhookForm) HasEvent(event models.WebhookEvent) bool {
+	for _, e := range form.Events {
+		if models.WebhookEvent(e) == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (app *application) renderWebhooks(w http.ResponseWriter, r *http.Request, status int, form webhookForm, created *models.Webhook) {
+	userID := app.authenticatedUserID(r)
+
+	hooks, err := app.webhooks.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	deliveries, err := app.webhooks.Deliveries(userID, webhookDeliveryCount)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Webhooks = hooks
+	data.WebhookEvents = models.WebhookEvents
+	data.WebhookDeliveries = deliveries
+	data.NewWebhook = created
+	data.Form = form
+
+	app.render(w, status, "webhooks.tmpl", data)
+}
+
+// accountWebhooks lists the current user's webhooks and recent deliveries.
+func (app *application) accountWebhooks(w http.ResponseWriter, r *http.Request) {
+	form := webhookForm{}
+	for _, e := range models.WebhookEvents {
+		form.Events = append(form.Events, string(e))
+	}
+
+	app.renderWebhooks(w, r, http.StatusOK, form, nil)
+}
+
+// accountWebhookCreatePost registers a webhook and shows its signing secret.
+func (app *application) accountWebhookCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form webhookForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+
+	hooks, err := app.webhooks.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	u, err := url.Parse(form.URL)
+	validURL := err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != "" && u.User == nil
+
+	form.CheckField(validator.NotBlank(form.URL), "url", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.URL, 2048), "url", "This field cannot be more than 2048 characters long")
+	form.CheckField(validURL, "url", "This field must be an http or https URL")
+	form.CheckField(len(form.Events) > 0, "event", "Select at least one event")
+	events := make([]models.WebhookEvent, 0, len(form.Events))
+	for _, e := range form.Events {
+		form.CheckField(models.WebhookEvent(e).Valid(), "event", "Unknown event")
+		events = append(events, models.WebhookEvent(e))
+	}
+	if len(hooks) >= maxWebhooks {
+		form.AddNonFieldError("You can't add any more webhooks, delete one first")
+	}
+
+	if !form.Valid() {
+		app.renderWebhooks(w, r, http.StatusUnprocessableEntity, form, nil)
+		return
+	}
+
+	id, err := app.webhooks.Insert(userID, form.URL, events)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	hook, err := app.webhooks.Get(userID, id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.renderWebhooks(w, r, http.StatusOK, webhookForm{Events: form.Events}, hook)
+}
+
+// webhookParam parses the webhook ID in the URL, responding with 404 if it
+// isn't valid.
+func (app *application) webhookParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return 0, false
+	}
+
+	return id, true
+}
+
+func (app *application) accountWebhookDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.webhookParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.webhooks.Delete(app.authenticatedUserID(r), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "The webhook has been deleted.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
+
+// accountWebhookPingPost queues a test delivery, which is useful for
+// checking a receiver's signature verification.
+func (app *application) accountWebhookPingPost(w http.ResponseWriter, r *http.Request) {
+	id, ok := app.webhookParam(w, r)
+	if !ok {
+		return
+	}
+
+	payload, err := json.Marshal(webhookPayload{Event: models.EventPing, Created: time.Now().UTC()})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.webhooks.EnqueuePing(app.authenticatedUserID(r), id, payload)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "A test event has been queued.")
+
+	http.Redirect(w, r, "/account/webhooks", http.StatusSeeOther)
+}
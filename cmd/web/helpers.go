@@ -2,21 +2,270 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/go-playground/form/v4"
+	"snippetbox.mattman.net/internal/models"
 )
 
 const (
-	sessionUserIdKey = "authenticatedUserID"
+	sessionUserIdKey          = "authenticatedUserID"
+	sessionPendingUserIdKey   = "pendingUserID"
+	sessionPendingAttemptsKey = "pendingAttempts"
+	sessionPendingRememberKey = "pendingRememberMe"
+	sessionRememberMeKey      = "rememberMe"
+	sessionLoginTimeKey       = "loginTime"
+	sessionLastActivityKey    = "lastActivity"
+	sessionLoginRedirectKey   = "loginRedirect"
 )
 
+// recentLoginWindow is how long after logging in a user may export or
+// delete their account without entering their password again.
+const recentLoginWindow = 5 * time.Minute
+
 func (app *application) isAuthenticated(r *http.Request) bool {
-	// app.infoLog.Printf("session: %#v", app.sessionManager.Get(r.Context(), sessionUserIdKey))
-	return app.sessionManager.Exists(r.Context(), sessionUserIdKey)
+	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+
+	return isAuthenticated
+}
+
+// authenticatedUser returns the logged in user, or nil.  Users whose account
+// has been disabled or deleted are not considered logged in.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(authenticatedUserContextKey).(*models.User)
+	if user == nil || !user.Active() {
+		return nil
+	}
+
+	return user
+}
+
+// hasRole reports whether the logged in user holds at least role.
+func (app *application) hasRole(r *http.Request, role models.Role) bool {
+	user := app.authenticatedUser(r)
+	return user != nil && user.Role.Includes(role)
+}
+
+// authenticatedUserID returns the ID of the logged in user, or zero.  It is
+// taken from the request context rather than the session so that it agrees
+// with isAuthenticated for requests authenticated by an API token.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return 0
+	}
+
+	return user.ID
+}
+
+// maxTags is the most tags a snippet may have.
+const maxTags = 10
+
+// parseTags splits a comma or space separated list of tags, lowercasing
+// them and dropping duplicates.
+func parseTags(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	tags := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+	for _, tag := range fields {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// canViewSnippet reports whether the current user may read a snippet.
+func (app *application) canViewSnippet(r *http.Request, s *models.Snippet) (bool, error) {
+	// hidden snippets remain visible to moderators so they can be restored
+	if s.Hidden && !app.hasRole(r, models.RoleModerator) {
+		return false, nil
+	}
+
+	if s.Visibility == models.VisibilityPublic {
+		return true, nil
+	}
+
+	// signed share links work without an account
+	if r.URL.Query().Has("sig") {
+		valid, err := app.validShareLink(r.URL.Query(), s)
+		if err != nil || valid {
+			return valid, err
+		}
+	}
+
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false, nil
+	}
+
+	if app.isSnippetOwner(r, s) {
+		return true, nil
+	}
+
+	if s.Visibility == models.VisibilityTeam && s.TeamID.Valid {
+		member, err := app.teams.IsMember(int(s.TeamID.Int64), user.ID)
+		if err != nil || member {
+			return member, err
+		}
+	}
+
+	// any share grants read access
+	permission, err := app.shares.Permission(s.ID, user.ID)
+	return permission != "", err
+}
+
+// canEditSnippet reports whether the current user may change a snippet,
+// either as its owner or by an edit share.
+func (app *application) canEditSnippet(r *http.Request, s *models.Snippet) (bool, error) {
+	if app.isSnippetOwner(r, s) {
+		return true, nil
+	}
+
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return false, nil
+	}
+
+	permission, err := app.shares.Permission(s.ID, user.ID)
+	return permission == models.ShareEdit, err
+}
+
+// isSnippetOwner reports whether the current user owns a snippet.
+func (app *application) isSnippetOwner(r *http.Request, s *models.Snippet) bool {
+	user := app.authenticatedUser(r)
+	return user != nil && s.UserID.Valid && int(s.UserID.Int64) == user.ID
+}
+
+// snippetAuthor returns the owner of a snippet for display, or nil if it
+// has none or the account is no longer active.
+func (app *application) snippetAuthor(s *models.Snippet) (*models.User, error) {
+	if !s.UserID.Valid {
+		return nil, nil
+	}
+
+	user, err := app.users.Get(int(s.UserID.Int64))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if !user.Active() {
+		return nil, nil
+	}
+
+	return user, nil
+}
+
+// logIn records the user as authenticated in the session, first changing
+// the session token to prevent session fixation.  Remembered sessions get a
+// persistent cookie and are exempt from the ordinary lifetime and idle
+// timeout.  The session is tracked so the user can see and revoke it later.
+func (app *application) logIn(r *http.Request, id int, remember bool) error {
+	// every login method ends here, so this is where disabled accounts are
+	// turned away
+	user, err := app.users.Get(id)
+	if err != nil {
+		return err
+	}
+	if !user.Active() {
+		return models.ErrAccountDisabled
+	}
+
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+
+	app.sessionManager.Put(r.Context(), sessionUserIdKey, id)
+	app.sessionManager.Put(r.Context(), sessionRememberMeKey, remember)
+	app.sessionManager.Put(r.Context(), sessionLoginTimeKey, now)
+	app.sessionManager.Put(r.Context(), sessionLastActivityKey, now)
+	app.sessionManager.RememberMe(r.Context(), remember)
+
+	token := app.sessionManager.Token(r.Context())
+	return app.userSessions.Insert(token, id, clientIP(r), r.UserAgent(), remember)
+}
+
+// recentLogin reports whether the current session was logged in within
+// recentLoginWindow, by any login method.
+func (app *application) recentLogin(r *http.Request) bool {
+	loginTime := time.Unix(app.sessionManager.GetInt64(r.Context(), sessionLoginTimeKey), 0)
+	return time.Since(loginTime) < recentLoginWindow
+}
+
+// loginRedirect returns where to send a user who has just logged in: back to
+// the page that asked them to log in again, if any, otherwise their
+// snippets.
+func (app *application) loginRedirect(r *http.Request) string {
+	switch path := app.sessionManager.PopString(r.Context(), sessionLoginRedirectKey); path {
+	case "/account/export", "/account/delete":
+		return path
+	default:
+		return "/snippet/mine"
+	}
+}
+
+// logOutInactive destroys the session of a user whose account is no longer
+// active, leaving a message explaining why in a fresh session.
+func (app *application) logOutInactive(r *http.Request) error {
+	err := app.userSessions.Remove(app.sessionManager.Token(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	err = app.sessionManager.Destroy(r.Context())
+	if err != nil {
+		return err
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Your account is no longer active.")
+
+	return nil
+}
+
+// renewSessionToken changes the token of an authenticated session while
+// keeping it associated with the user.
+func (app *application) renewSessionToken(r *http.Request) error {
+	oldToken := app.sessionManager.Token(r.Context())
+
+	err := app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		return err
+	}
+
+	return app.userSessions.Rename(oldToken, app.sessionManager.Token(r.Context()))
+}
+
+// clientIP returns the address of the client without the port.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
 }
 
 func (app *application) decodePostForm(r *http.Request, dst any) error {
@@ -41,6 +290,12 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 }
 
 func (app *application) render(w http.ResponseWriter, status int, page string, data *templateData) {
+	app.renderNamed(w, status, page, "base", data)
+}
+
+// renderNamed is like render for pages that don't use the base layout,
+// executing the named template from the page's set instead.
+func (app *application) renderNamed(w http.ResponseWriter, status int, page, name string, data *templateData) {
 	if !app.enableCache {
 		cache, err := newTemplateCache()
 		if err != nil {
@@ -59,7 +314,7 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 
 	// validate correct template rendering by initially writing to a buffer
 	var buf bytes.Buffer
-	err := ts.ExecuteTemplate(&buf, "base", data)
+	err := ts.ExecuteTemplate(&buf, name, data)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -70,6 +325,19 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 	buf.WriteTo(w)
 }
 
+// writeJSON encodes data as the JSON response body.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
+
 func (app *application) serverError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	app.errorLog.Output(2, trace) // report error location from 2 stack frames up
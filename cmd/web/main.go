@@ -1,12 +1,15 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"flag"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -15,31 +18,120 @@ import (
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/graphql-go/graphql"
+	"snippetbox.mattman.net/internal/mailer"
 	"snippetbox.mattman.net/internal/models"
 )
 
 type application struct {
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       *models.SnippetModel
-	users          *models.UserModel
-	templateCache  map[string]*template.Template
-	enableCache    bool
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	errorLog            *log.Logger
+	infoLog             *log.Logger
+	snippets            *models.SnippetModel
+	users               models.UserModelInterface
+	totp                *models.TOTPModel
+	webauthnCredentials models.WebAuthnModelInterface
+	identities          models.IdentityModelInterface
+	userSessions        models.UserSessionModelInterface
+	teams               *models.TeamModel
+	shares              *models.ShareModel
+	apiTokens           *models.APITokenModel
+	webhooks            models.WebhookModelInterface
+	webhookClient       *http.Client
+	embedOrigins        []string
+	graphQLSchema       graphql.Schema
+	templateCache       map[string]*template.Template
+	enableCache         bool
+	formDecoder         *form.Decoder
+	sessionManager      *scs.SessionManager
+	webAuthn            *webauthn.WebAuthn
+	oidcProviders       map[string]*oidcProvider
+	oidcLinks           []oidcProviderLink
+	mailer              *mailer.Mailer
+	origin              string
+	sessionLifetime     time.Duration
+	idleTimeout         time.Duration
+	purgeGrace          time.Duration
+	deletionPolicy      string
+	shareKey            []byte
 }
 
 func main() {
 	addr := flag.String("addr", ":4000", "HTTP network address and port")
+	grpcAddr := flag.String("grpc-addr", "", "gRPC network address and port, e.g. \":4001\", the gRPC API is disabled when empty")
 	// note: parseTime=true is driver-specific config to convert datetimes to time.Time
 	//dsn := flag.String("dsn", "web:dev@/snippetbox?parseTime=true", "MySQL datasouce name")
 	dsn := flag.String("dsn", "web:dev@/snippetbox?parseTime=true&charset=utf8mb4&collation=utf8mb4_unicode_ci", "MySQL datasouce name")
 	noCache := flag.Bool("nocache", false, "disable template caching")
+	totpKey := flag.String("totp-key", "", "hex-encoded 32 byte key used to encrypt TOTP secrets (required)")
+	shareKey := flag.String("share-key", "", "hex-encoded 32 byte key used to sign snippet share links, random when empty so links stop working on restart")
+	origin := flag.String("origin", "https://localhost:4000", "public origin of the site, used as the WebAuthn relying party")
+	oidcConfig := flag.String("oidc-config", "", "path to JSON file configuring OpenID Connect providers (see oidc-providers.example.json)")
+	smtpHost := flag.String("smtp-host", "", "SMTP server host, email is logged when empty")
+	smtpPort := flag.Int("smtp-port", 587, "SMTP server port")
+	smtpUsername := flag.String("smtp-username", "", "SMTP username")
+	smtpPassword := flag.String("smtp-password", "", "SMTP password")
+	smtpSender := flag.String("smtp-sender", "Snippetbox <no-reply@snippetbox.mattman.net>", "SMTP sender address")
+	sessionLifetime := flag.Duration("session-lifetime", 12*time.Hour, "maximum lifetime of an ordinary login session")
+	idleTimeout := flag.Duration("idle-timeout", time.Hour, "inactivity after which an ordinary login session expires")
+	rememberLifetime := flag.Duration("remember-lifetime", 30*24*time.Hour, "lifetime of a \"remember me\" login session")
+	purgeGrace := flag.Duration("purge-grace", 30*24*time.Hour, "how long deleted accounts and their snippets are retained before being purged")
+	deletionPolicy := flag.String("deletion-policy", deletionPolicyDelete, "what happens to a user's snippets when they delete their account: \"delete\" or \"anonymize\"")
+	webhookAllowPrivate := flag.Bool("webhook-allow-private", false, "allow webhooks to loopback and private network addresses, e.g. to test against a local receiver")
+	embedOriginList := flag.String("embed-origins", "", "space separated origins allowed to embed snippets in an iframe, e.g. \"https://wiki.example.com\"")
 	flag.Parse()
 
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// secrets sealed with a random key would be unreadable after a restart,
+	// so there is no fallback for the TOTP key
+	totpKeyBytes, err := hex.DecodeString(*totpKey)
+	if err != nil || len(totpKeyBytes) != 32 {
+		errorLog.Fatal("totp-key must be 32 hex-encoded bytes, e.g. from `openssl rand -hex 32`")
+	}
+
+	shareKeyBytes := make([]byte, 32)
+	if *shareKey == "" {
+		if _, err := rand.Read(shareKeyBytes); err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Print("no share-key given, share links will stop working when the server restarts")
+	} else {
+		shareKeyBytes, err = hex.DecodeString(*shareKey)
+		if err != nil || len(shareKeyBytes) != 32 {
+			errorLog.Fatal("share-key must be 32 hex-encoded bytes")
+		}
+	}
+
+	if *deletionPolicy != deletionPolicyDelete && *deletionPolicy != deletionPolicyAnonymize {
+		errorLog.Fatal("deletion-policy must be \"delete\" or \"anonymize\"")
+	}
+
+	embedOrigins, err := parseEmbedOrigins(*embedOriginList)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	originURL, err := url.Parse(*origin)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          originURL.Hostname(),
+		RPDisplayName: "Snippetbox",
+		RPOrigins:     []string{*origin},
+	})
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	oidcProviders, oidcLinks, err := loadOIDCProviders(*oidcConfig, *origin)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
 	db, err := openDB(*dsn)
 	if err != nil {
 		errorLog.Fatal(err)
@@ -56,19 +148,66 @@ func main() {
 
 	sessionManager := scs.New()
 	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
+	// remembered sessions persist for the full Lifetime, ordinary sessions
+	// are cut short by the expireSessions middleware and, for sessions that
+	// are never used again, by UserSessionModel.ForUser
+	sessionManager.Lifetime = *rememberLifetime
+	sessionManager.Cookie.Persist = false
 
 	app := &application{
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		enableCache:    !*noCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
+		errorLog:            errorLog,
+		infoLog:             infoLog,
+		snippets:            &models.SnippetModel{DB: db},
+		users:               &models.UserModel{DB: db},
+		totp:                &models.TOTPModel{DB: db, EncryptionKey: totpKeyBytes},
+		webauthnCredentials: &models.WebAuthnModel{DB: db},
+		identities:          &models.IdentityModel{DB: db},
+		userSessions:        &models.UserSessionModel{DB: db},
+		teams:               &models.TeamModel{DB: db},
+		shares:              &models.ShareModel{DB: db},
+		apiTokens:           &models.APITokenModel{DB: db},
+		webhooks:            &models.WebhookModel{DB: db},
+		webhookClient:       newWebhookClient(*webhookAllowPrivate),
+		embedOrigins:        embedOrigins,
+		templateCache:       templateCache,
+		enableCache:         !*noCache,
+		formDecoder:         formDecoder,
+		sessionManager:      sessionManager,
+		webAuthn:            webAuthn,
+		oidcProviders:       oidcProviders,
+		oidcLinks:           oidcLinks,
+		mailer: &mailer.Mailer{
+			Host:     *smtpHost,
+			Port:     *smtpPort,
+			Username: *smtpUsername,
+			Password: *smtpPassword,
+			Sender:   *smtpSender,
+			Log:      infoLog,
+		},
+		origin:          strings.TrimSuffix(*origin, "/"),
+		sessionLifetime: *sessionLifetime,
+		idleTimeout:     *idleTimeout,
+		purgeGrace:      *purgeGrace,
+		deletionPolicy:  *deletionPolicy,
+		shareKey:        shareKeyBytes,
+	}
+
+	// the OpenAPI spec is maintained by hand, refuse to start if it has
+	// drifted from the routes
+	handler, routes := app.routeTable()
+	err = checkOpenAPISpec(routes)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	app.graphQLSchema, err = app.newGraphQLSchema()
+	if err != nil {
+		errorLog.Fatal(err)
 	}
 
+	go app.purgeDeletedUsers(time.Hour)
+	go app.deliverWebhooks(10 * time.Second)
+
 	// configure non-default TLS security settings
 	tlsConfig := tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
@@ -76,10 +215,16 @@ func main() {
 		MaxVersion:       tls.VersionTLS13,
 	}
 
+	if *grpcAddr != "" {
+		go func() {
+			errorLog.Fatal(app.serveGRPC(*grpcAddr, &tlsConfig))
+		}()
+	}
+
 	srv := &http.Server{
 		Addr:      *addr,
 		ErrorLog:  errorLog,
-		Handler:   app.routes(),
+		Handler:   handler,
 		TLSConfig: &tlsConfig,
 
 		IdleTimeout: time.Minute,
@@ -121,3 +266,22 @@ func disableDirListing(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// purgeDeletedUsers periodically removes accounts whose soft-delete grace
+// period has passed.
+func (app *application) purgeDeletedUsers(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		n, err := app.users.PurgeDeleted(app.purgeGrace)
+		if err != nil {
+			app.errorLog.Printf("purging deleted users: %v", err)
+			continue
+		}
+
+		if n > 0 {
+			app.infoLog.Printf("purged %d deleted users", n)
+		}
+	}
+}
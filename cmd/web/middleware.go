@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
 )
 
 func (app *application) logRequest(next http.Handler) http.Handler {
@@ -18,6 +23,17 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		if !app.isAuthenticated(r) {
+			// cut off existing sessions of accounts disabled or deleted since
+			// they logged in
+			user, _ := r.Context().Value(authenticatedUserContextKey).(*models.User)
+			if user != nil {
+				err := app.logOutInactive(r)
+				if err != nil {
+					app.serverError(w, err)
+					return
+				}
+			}
+
 			// app.infoLog.Printf("user not authenticated, redirecting to login form...")
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
@@ -30,9 +46,109 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// expireSessions logs out sessions that were not "remembered" once they
+// exceed the ordinary session lifetime or have been idle for too long.
+// Remembered sessions are only bounded by the session manager's Lifetime.
+func (app *application) expireSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if !app.sessionManager.Exists(ctx, sessionUserIdKey) || app.sessionManager.GetBool(ctx, sessionRememberMeKey) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		loginTime := time.Unix(app.sessionManager.GetInt64(ctx, sessionLoginTimeKey), 0)
+		lastActivity := time.Unix(app.sessionManager.GetInt64(ctx, sessionLastActivityKey), 0)
+
+		switch {
+		case now.Sub(loginTime) > app.sessionLifetime || now.Sub(lastActivity) > app.idleTimeout:
+			err := app.userSessions.Remove(app.sessionManager.Token(ctx))
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			err = app.sessionManager.Destroy(ctx)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			// further use of the session manager starts a fresh session
+			app.sessionManager.Put(ctx, "flash", "Your session has expired.  Please log in again.")
+
+		case now.Sub(lastActivity) > time.Minute:
+			// avoid rewriting the session on every request
+			app.sessionManager.Put(ctx, sessionLastActivityKey, now.Unix())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate confirms the user ID stored in the session still refers to an
+// existing user and records the user in the request context.  Only active
+// users count as authenticated; requireAuthentication ends the sessions of
+// disabled and deleted users.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := app.sessionManager.GetInt(r.Context(), sessionUserIdKey)
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.users.Get(id)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		if user != nil {
+			err = app.userSessions.Touch(app.sessionManager.Token(r.Context()), clientIP(r))
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), authenticatedUserContextKey, user)
+			ctx = context.WithValue(ctx, isAuthenticatedContextKey, user.Active())
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole restricts access to authenticated users holding at least the
+// given role.  It is an alice.Constructor, so it can be appended to a chain
+// after requireAuthentication.
+func (app *application) requireRole(role models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := app.authenticatedUser(r)
+			if user == nil {
+				http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+				return
+			}
+
+			if !user.Role.Includes(role) {
+				app.clientError(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+const contentSecurityPolicy = "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com"
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' fonts.googleapis.com; font-src fonts.gstatic.com")
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 		w.Header().Set("Referrer-Policy", "origin-when-cross-origin")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "deny")
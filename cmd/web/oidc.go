@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
+	"snippetbox.mattman.net/internal/models"
+)
+
+const (
+	sessionOIDCStateKey    = "oidcState"
+	sessionOIDCNonceKey    = "oidcNonce"
+	sessionOIDCVerifierKey = "oidcVerifier"
+)
+
+// oidcProviderConfig is the configuration of a single identity provider as
+// read from the JSON file given by the -oidc-config flag.
+type oidcProviderConfig struct {
+	Name          string   `json:"name"` // used in URLs and to link identities
+	DisplayName   string   `json:"display_name"`
+	Issuer        string   `json:"issuer"`
+	ClientID      string   `json:"client_id"`
+	ClientSecret  string   `json:"client_secret"`
+	Scopes        []string `json:"scopes"`
+	AutoProvision bool     `json:"auto_provision"` // create provider-only users on first login
+	LinkExisting  bool     `json:"link_existing"`  // link users whose verified email matches
+}
+
+type oidcProvider struct {
+	config   oidcProviderConfig
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+// oidcProviderLink is what templates need to offer a provider on the login page.
+type oidcProviderLink struct {
+	Name        string
+	DisplayName string
+}
+
+// loadOIDCProviders reads the provider configuration file and performs
+// discovery against each issuer.  An empty path disables OIDC login.
+func loadOIDCProviders(path, origin string) (map[string]*oidcProvider, []oidcProviderLink, error) {
+	providers := make(map[string]*oidcProvider)
+	links := make([]oidcProviderLink, 0)
+
+	if path == "" {
+		return providers, links, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var configs []oidcProviderConfig
+	err = json.Unmarshal(b, &configs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, c := range configs {
+		if c.Name == "" || c.Issuer == "" || c.ClientID == "" {
+			return nil, nil, fmt.Errorf("oidc provider %q: name, issuer and client_id are required", c.Name)
+		}
+
+		if _, exists := providers[c.Name]; exists {
+			return nil, nil, fmt.Errorf("oidc provider %q is configured twice", c.Name)
+		}
+
+		if c.DisplayName == "" {
+			c.DisplayName = c.Name
+		}
+
+		p, err := oidc.NewProvider(context.Background(), c.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc provider %q: %w", c.Name, err)
+		}
+
+		scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+		scopes = append(scopes, c.Scopes...)
+
+		providers[c.Name] = &oidcProvider{
+			config:   c,
+			verifier: p.Verifier(&oidc.Config{ClientID: c.ClientID}),
+			oauth2: oauth2.Config{
+				ClientID:     c.ClientID,
+				ClientSecret: c.ClientSecret,
+				Endpoint:     p.Endpoint(),
+				RedirectURL:  strings.TrimSuffix(origin, "/") + "/user/login/oidc/" + c.Name + "/callback",
+				Scopes:       scopes,
+			},
+		}
+		links = append(links, oidcProviderLink{Name: c.Name, DisplayName: c.DisplayName})
+	}
+
+	return providers, links, nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// userLoginOIDC redirects to the provider's authorization endpoint using the
+// authorization code flow with PKCE.
+func (app *application) userLoginOIDC(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	provider, ok := app.oidcProviders[params.ByName("provider")]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	state, err := randomString()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	nonce, err := randomString()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	verifier := oauth2.GenerateVerifier()
+
+	app.sessionManager.Put(r.Context(), sessionOIDCStateKey, state)
+	app.sessionManager.Put(r.Context(), sessionOIDCNonceKey, nonce)
+	app.sessionManager.Put(r.Context(), sessionOIDCVerifierKey, verifier)
+
+	authURL := provider.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcClaims are the ID token claims used to find or create the local user.
+type oidcClaims struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+func (app *application) userLoginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	provider, ok := app.oidcProviders[params.ByName("provider")]
+	if !ok {
+		app.notFound(w)
+		return
+	}
+
+	state := app.sessionManager.PopString(r.Context(), sessionOIDCStateKey)
+	nonce := app.sessionManager.PopString(r.Context(), sessionOIDCNonceKey)
+	verifier := app.sessionManager.PopString(r.Context(), sessionOIDCVerifierKey)
+
+	query := r.URL.Query()
+
+	if state == "" || query.Get("state") != state {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if errCode := query.Get("error"); errCode != "" {
+		app.infoLog.Printf("oidc provider %q returned error: %s", provider.config.Name, errCode)
+		app.oidcLoginFailed(w, r, "Log in was cancelled or refused by the identity provider.")
+		return
+	}
+
+	token, err := provider.oauth2.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		app.infoLog.Printf("oidc provider %q code exchange failed: %v", provider.config.Name, err)
+		app.oidcLoginFailed(w, r, "Log in with the identity provider failed.")
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		app.oidcLoginFailed(w, r, "The identity provider did not return an ID token.")
+		return
+	}
+
+	idToken, err := provider.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != nonce {
+		app.infoLog.Printf("oidc provider %q returned an invalid ID token: %v", provider.config.Name, err)
+		app.oidcLoginFailed(w, r, "The identity provider returned an invalid ID token.")
+		return
+	}
+
+	var claims oidcClaims
+	err = idToken.Claims(&claims)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	id, err := app.oidcUserID(provider, idToken.Subject, claims)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrNoRecord):
+			app.oidcLoginFailed(w, r, "There is no Snippetbox account for that identity.  Please sign up first.")
+		case errors.Is(err, models.ErrDuplicateEmail):
+			app.oidcLoginFailed(w, r, "A Snippetbox account already uses that email address.  Please log in with your password.")
+		default:
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// the identity provider is responsible for any second factor
+	err = app.logIn(r, id, false)
+	if err != nil {
+		if errors.Is(err, models.ErrAccountDisabled) {
+			app.oidcLoginFailed(w, r, "Your account has been disabled.")
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, app.loginRedirect(r), http.StatusSeeOther)
+}
+
+// oidcUserID finds the local user for a provider subject, linking an existing
+// account or provisioning a new one by verified email address, as the
+// provider's configuration allows.
+func (app *application) oidcUserID(provider *oidcProvider, subject string, claims oidcClaims) (int, error) {
+	name := provider.config.Name
+
+	id, err := app.identities.UserID(name, subject)
+	if err == nil || !errors.Is(err, models.ErrNoRecord) {
+		return id, err
+	}
+
+	// unverified addresses could be used to take over existing accounts
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, models.ErrNoRecord
+	}
+
+	if provider.config.LinkExisting {
+		id, err = app.identities.Link(claims.Email, name, subject)
+		if err == nil || !errors.Is(err, models.ErrNoRecord) {
+			return id, err
+		}
+	}
+
+	if !provider.config.AutoProvision {
+		return 0, models.ErrNoRecord
+	}
+
+	displayName := claims.Name
+	if displayName == "" {
+		displayName = strings.Split(claims.Email, "@")[0]
+	}
+
+	handle, err := oidcHandle(claims.Email)
+	if err != nil {
+		return 0, err
+	}
+
+	return app.identities.Provision(displayName, handle, claims.Email, name, subject)
+}
+
+// oidcHandle derives a handle for a provisioned user from their email
+// address, with a random suffix so it is very unlikely to be taken.
+func oidcHandle(email string) (string, error) {
+	var b strings.Builder
+	for _, c := range strings.ToLower(strings.Split(email, "@")[0]) {
+		if b.Len() == 20 {
+			break
+		}
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' {
+			b.WriteRune(c)
+		}
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s_%x", b.String(), suffix), nil
+}
+
+func (app *application) oidcLoginFailed(w http.ResponseWriter, r *http.Request, msg string) {
+	app.sessionManager.Put(r.Context(), "flash", msg)
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
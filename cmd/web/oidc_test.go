@@ -0,0 +1,300 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// testIdP is an OpenID Connect provider serving discovery, JWKS and token
+// endpoints.  The token endpoint issues an ID token with the claims set by
+// the test.
+type testIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	claims map[string]any
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "code" || r.PostFormValue("code_verifier") == "" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		idToken, err := idp.idToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func (idp *testIdP) setClaims(claims map[string]any) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	idp.claims = claims
+}
+
+func (idp *testIdP) idToken() (string, error) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	now := time.Now()
+	claims := map[string]any{
+		"iss": idp.URL,
+		"aud": "snippetbox",
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	for k, v := range idp.claims {
+		claims[k] = v
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: idp.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return jws.CompactSerialize()
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := newTestIdP(t)
+
+	config := []oidcProviderConfig{
+		{Name: "plain", Issuer: idp.URL, ClientID: "snippetbox"},
+		{Name: "link", Issuer: idp.URL, ClientID: "snippetbox", LinkExisting: true},
+		{Name: "provision", Issuer: idp.URL, ClientID: "snippetbox", AutoProvision: true},
+	}
+	b, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "oidc-providers.json")
+	err = os.WriteFile(path, b, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.oidcProviders, app.oidcLinks, err = loadOIDCProviders(path, app.origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alice has confirmed her email address, bob has not
+	err = app.users.Insert("Alice", "alice", "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.users.NewEmailChange(1, "alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.users.ConfirmEmailChange(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.users.Insert("Bob", "bob", "bob@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// carol has already logged in with the plain provider
+	_, err = app.identities.Provision("Carol", "carol", "carol@example.com", "plain", "carol-subject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		noAccount  = "There is no Snippetbox account for that identity."
+		emailTaken = "A Snippetbox account already uses that email address."
+	)
+
+	tests := []struct {
+		name          string
+		provider      string
+		subject       string
+		email         string
+		emailVerified bool
+		wantUserID    int
+		wantFlash     string
+	}{
+		{
+			name:          "Linked identity",
+			provider:      "plain",
+			subject:       "carol-subject",
+			email:         "carol@example.com",
+			emailVerified: true,
+			wantUserID:    3,
+		},
+		{
+			name:          "Link verified email",
+			provider:      "link",
+			subject:       "alice-subject",
+			email:         "alice@example.com",
+			emailVerified: true,
+			wantUserID:    1,
+		},
+		{
+			name:          "Link unverified local email",
+			provider:      "link",
+			subject:       "bob-subject",
+			email:         "bob@example.com",
+			emailVerified: true,
+			wantFlash:     noAccount,
+		},
+		{
+			name:          "Link unverified provider email",
+			provider:      "link",
+			subject:       "mallory-subject",
+			email:         "alice@example.com",
+			emailVerified: false,
+			wantFlash:     noAccount,
+		},
+		{
+			name:          "Linking disabled",
+			provider:      "plain",
+			subject:       "alice-subject",
+			email:         "alice@example.com",
+			emailVerified: true,
+			wantFlash:     noAccount,
+		},
+		{
+			name:          "Provision existing email",
+			provider:      "provision",
+			subject:       "alice-subject",
+			email:         "alice@example.com",
+			emailVerified: true,
+			wantFlash:     emailTaken,
+		},
+		{
+			name:          "Provision new user",
+			provider:      "provision",
+			subject:       "dave-subject",
+			email:         "dave@example.com",
+			emailVerified: true,
+			wantUserID:    4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+
+			code, header, _ := ts.get(t, "/user/login/oidc/"+tt.provider)
+			if code != http.StatusFound {
+				t.Fatalf("got status %d; want %d", code, http.StatusFound)
+			}
+
+			authURL, err := url.Parse(header.Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(authURL.String(), idp.URL+"/authorize") {
+				t.Fatalf("redirected to %s; want the authorization endpoint", authURL)
+			}
+
+			params := authURL.Query()
+			idp.setClaims(map[string]any{
+				"sub":            tt.subject,
+				"nonce":          params.Get("nonce"),
+				"email":          tt.email,
+				"email_verified": tt.emailVerified,
+			})
+
+			callback := url.Values{"code": {"code"}, "state": {params.Get("state")}}
+			code, header, _ = ts.get(t, "/user/login/oidc/"+tt.provider+"/callback?"+callback.Encode())
+			if code != http.StatusSeeOther {
+				t.Fatalf("got status %d; want %d", code, http.StatusSeeOther)
+			}
+
+			session := ts.session(t, app.sessionManager)
+
+			if tt.wantFlash != "" {
+				if location := header.Get("Location"); location != "/user/login" {
+					t.Errorf("redirected to %s; want /user/login", location)
+				}
+				if flash := app.sessionManager.GetString(session, "flash"); !strings.HasPrefix(flash, tt.wantFlash) {
+					t.Errorf("got flash %q; want %q", flash, tt.wantFlash)
+				}
+				if id := app.sessionManager.GetInt(session, sessionUserIdKey); id != 0 {
+					t.Errorf("logged in as user %d; want no login", id)
+				}
+				return
+			}
+
+			if location := header.Get("Location"); location != "/snippet/mine" {
+				t.Errorf("redirected to %s; want /snippet/mine", location)
+			}
+			if id := app.sessionManager.GetInt(session, sessionUserIdKey); id != tt.wantUserID {
+				t.Errorf("logged in as user %d; want %d", id, tt.wantUserID)
+			}
+			if id, err := app.identities.UserID(tt.provider, tt.subject); err != nil || id != tt.wantUserID {
+				t.Errorf("identity linked to user %d (%v); want %d", id, err, tt.wantUserID)
+			}
+		})
+	}
+}
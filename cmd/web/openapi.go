@@ -0,0 +1,72 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// openAPISpec describes the JSON API.  It is written by hand, so
+// checkOpenAPISpec compares it with the registered routes at startup.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIPathParam matches httprouter's named parameters, e.g. ":id".
+var openAPIPathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// openAPIPath converts an httprouter path to an OpenAPI path template.
+func openAPIPath(path string) string {
+	return openAPIPathParam.ReplaceAllString(path, "{$1}")
+}
+
+// openAPIMethods are the keys of an OpenAPI path item naming operations.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// checkOpenAPISpec reports routes under /api/ missing from the spec, and
+// operations in the spec that have no route.
+func checkOpenAPISpec(routes []route) error {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	err := json.Unmarshal(openAPISpec, &spec)
+	if err != nil {
+		return fmt.Errorf("openapi.json: %w", err)
+	}
+
+	var errs []error
+	registered := make(map[string]bool)
+
+	for _, rt := range routes {
+		if !strings.HasPrefix(rt.path, "/api/") {
+			continue
+		}
+
+		path, method := openAPIPath(rt.path), strings.ToLower(rt.method)
+		registered[method+" "+path] = true
+
+		if _, ok := spec.Paths[path][method]; !ok {
+			errs = append(errs, fmt.Errorf("openapi.json: missing %s %s", rt.method, path))
+		}
+	}
+
+	for path, item := range spec.Paths {
+		for _, method := range openAPIMethods {
+			if _, ok := item[method]; ok && !registered[method+" "+path] {
+				errs = append(errs, fmt.Errorf("openapi.json: %s %s has no route", strings.ToUpper(method), path))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (app *application) openAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	app := newTestApplication(t)
+	_, routes := app.routeTable()
+
+	var withoutSpecRoute []route
+	for _, rt := range routes {
+		if rt.path != "/api/openapi.json" {
+			withoutSpecRoute = append(withoutSpecRoute, rt)
+		}
+	}
+
+	// a route added straight to the router, bypassing apiRoutes
+	router := &routeRecorder{Router: httprouter.New(), routes: routes[:len(routes):len(routes)]}
+	router.Handle(http.MethodGet, "/api/v1/missing/:id", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+	router.GET("/api/v1/unlisted", func(http.ResponseWriter, *http.Request, httprouter.Params) {})
+
+	tests := []struct {
+		name    string
+		routes  []route
+		wantErr []string
+	}{
+		{
+			name:   "Matches routes",
+			routes: routes,
+		},
+		{
+			name:    "Route missing from spec",
+			routes:  router.routes,
+			wantErr: []string{"missing GET /api/v1/missing/{id}", "missing GET /api/v1/unlisted"},
+		},
+		{
+			name:    "Spec operation without route",
+			routes:  withoutSpecRoute,
+			wantErr: []string{"GET /api/openapi.json has no route"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOpenAPISpec(tt.routes)
+
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatalf("got no error; want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("got error %v; want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -2,43 +2,248 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
+	"snippetbox.mattman.net/internal/models"
 )
 
 func (app *application) routes() http.Handler {
+	handler, _ := app.routeTable()
+	return handler
+}
+
+// routeTable builds the application's handler and also returns every route
+// registered on its router, so they can be checked against the OpenAPI spec.
+func (app *application) routeTable() (http.Handler, []route) {
 
-	router := httprouter.New()
+	router := &routeRecorder{Router: httprouter.New()}
 
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiClientError(w, http.StatusNotFound)
+			return
+		}
+
 		app.notFound(w)
 	})
 
+	router.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiClientError(w, http.StatusMethodNotAllowed)
+			return
+		}
+
+		app.clientError(w, http.StatusMethodNotAllowed)
+	})
+
 	// server static files from ./ui/static at URI base /static
 	fileServer := http.FileServer(http.Dir("./ui/static/"))
 	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
 
+	// feeds are public and don't need a session
+	router.HandlerFunc(http.MethodGet, "/feed.atom", app.feedAtom)
+	router.HandlerFunc(http.MethodGet, "/feed.rss", app.feedRSS)
+
+	// embedding, also without a session since embeds are third-party frames
+	router.HandlerFunc(http.MethodGet, "/oembed", app.oEmbed)
+	router.Handler(http.MethodGet, "/snippet/embed/:id", app.allowFraming(http.HandlerFunc(app.snippetEmbed)))
+
 	// create middleware to manage session and register snippet routes
-	dynamic := alice.New(app.sessionManager.LoadAndSave)
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.expireSessions, app.authenticate)
 
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/u/:handle", dynamic.ThenFunc(app.userProfile))
 
 	// user auth routes
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
 	router.Handler(http.MethodPost, "/user/login", dynamic.ThenFunc(app.userLoginPost))
+	router.Handler(http.MethodGet, "/user/login/totp", dynamic.ThenFunc(app.userLoginTOTP))
+	router.Handler(http.MethodPost, "/user/login/totp", dynamic.ThenFunc(app.userLoginTOTPPost))
+	router.Handler(http.MethodPost, "/user/login/passkey/begin", dynamic.ThenFunc(app.userLoginPasskeyBegin))
+	router.Handler(http.MethodPost, "/user/login/passkey/finish", dynamic.ThenFunc(app.userLoginPasskeyFinish))
+	router.Handler(http.MethodGet, "/user/login/oidc/:provider", dynamic.ThenFunc(app.userLoginOIDC))
+	router.Handler(http.MethodGet, "/user/login/oidc/:provider/callback", dynamic.ThenFunc(app.userLoginOIDCCallback))
+
+	// email verification links may be opened in a browser without a session
+	router.Handler(http.MethodGet, "/account/email/verify", dynamic.ThenFunc(app.accountEmailVerify))
 
 	// protected routes that require auth
 	protected := dynamic.Append(app.requireAuthentication)
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/mine", protected.ThenFunc(app.snippetMine))
+	router.Handler(http.MethodPost, "/snippet/mine/bulk", protected.ThenFunc(app.snippetMineBulkPost))
+	router.Handler(http.MethodGet, "/snippet/shared", protected.ThenFunc(app.snippetShared))
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/share/:id", protected.ThenFunc(app.snippetSharePost))
+	router.Handler(http.MethodPost, "/snippet/unshare/:id", protected.ThenFunc(app.snippetUnsharePost))
+	router.Handler(http.MethodPost, "/snippet/link/create/:id", protected.ThenFunc(app.snippetLinkPost))
+	router.Handler(http.MethodPost, "/snippet/link/revoke/:id", protected.ThenFunc(app.snippetLinkRevokePost))
+
+	// teams
+	router.Handler(http.MethodGet, "/teams", protected.ThenFunc(app.teamList))
+	router.Handler(http.MethodPost, "/teams/create", protected.ThenFunc(app.teamCreatePost))
+	router.Handler(http.MethodGet, "/teams/view/:id", protected.ThenFunc(app.teamView))
+	router.Handler(http.MethodGet, "/teams/join", protected.ThenFunc(app.teamJoin))
+	router.Handler(http.MethodPost, "/teams/invite/:id", protected.ThenFunc(app.teamInvitePost))
+	router.Handler(http.MethodPost, "/teams/members/role/:id", protected.ThenFunc(app.teamMemberRolePost))
+	router.Handler(http.MethodPost, "/teams/members/remove/:id", protected.ThenFunc(app.teamMemberRemovePost))
+	router.Handler(http.MethodPost, "/teams/leave/:id", protected.ThenFunc(app.teamLeavePost))
+	router.Handler(http.MethodPost, "/teams/delete/:id", protected.ThenFunc(app.teamDeletePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
 
+	// account settings
+	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
+	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/email/update", protected.ThenFunc(app.accountEmailUpdate))
+	router.Handler(http.MethodPost, "/account/email/update", protected.ThenFunc(app.accountEmailUpdatePost))
+	router.Handler(http.MethodGet, "/account/sessions", protected.ThenFunc(app.accountSessions))
+	router.Handler(http.MethodPost, "/account/sessions/revoke/:id", protected.ThenFunc(app.accountSessionRevokePost))
+	router.Handler(http.MethodPost, "/account/sessions/revoke-others", protected.ThenFunc(app.accountSessionRevokeOthersPost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountAPITokens))
+	router.Handler(http.MethodPost, "/account/tokens/create", protected.ThenFunc(app.accountAPITokenCreatePost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.accountAPITokenRevokePost))
+	router.Handler(http.MethodGet, "/account/webhooks", protected.ThenFunc(app.accountWebhooks))
+	router.Handler(http.MethodPost, "/account/webhooks/create", protected.ThenFunc(app.accountWebhookCreatePost))
+	router.Handler(http.MethodPost, "/account/webhooks/delete/:id", protected.ThenFunc(app.accountWebhookDeletePost))
+	router.Handler(http.MethodPost, "/account/webhooks/ping/:id", protected.ThenFunc(app.accountWebhookPingPost))
+	router.Handler(http.MethodGet, "/account/export", protected.ThenFunc(app.accountExport))
+	router.Handler(http.MethodPost, "/account/export", protected.ThenFunc(app.accountExportPost))
+	router.Handler(http.MethodGet, "/account/delete", protected.ThenFunc(app.accountDelete))
+	router.Handler(http.MethodPost, "/account/delete", protected.ThenFunc(app.accountDeletePost))
+	router.Handler(http.MethodPost, "/account/reauth", protected.ThenFunc(app.accountReauthPost))
+
+	// two-factor authentication enrollment
+	router.Handler(http.MethodGet, "/account/totp", protected.ThenFunc(app.accountTOTP))
+	router.Handler(http.MethodGet, "/account/totp/qr.png", protected.ThenFunc(app.accountTOTPQRCode))
+	router.Handler(http.MethodPost, "/account/totp/setup", protected.ThenFunc(app.accountTOTPSetupPost))
+	router.Handler(http.MethodPost, "/account/totp/enable", protected.ThenFunc(app.accountTOTPEnablePost))
+	router.Handler(http.MethodPost, "/account/totp/disable", protected.ThenFunc(app.accountTOTPDisablePost))
+
+	// passkey management
+	router.Handler(http.MethodGet, "/account/passkeys", protected.ThenFunc(app.accountPasskeys))
+	router.Handler(http.MethodPost, "/account/passkeys/register/begin", protected.ThenFunc(app.accountPasskeyRegisterBegin))
+	router.Handler(http.MethodPost, "/account/passkeys/register/finish", protected.ThenFunc(app.accountPasskeyRegisterFinish))
+	router.Handler(http.MethodPost, "/account/passkeys/delete/:id", protected.ThenFunc(app.accountPasskeyDeletePost))
+
+	// moderation and administration routes
+	moderator := protected.Append(app.requireRole(models.RoleModerator))
+	router.Handler(http.MethodPost, "/snippet/hide/:id", moderator.ThenFunc(app.snippetHidePost))
+
+	admin := protected.Append(app.requireRole(models.RoleAdmin))
+	router.Handler(http.MethodGet, "/admin", admin.ThenFunc(app.adminDashboard))
+	router.Handler(http.MethodGet, "/admin/users", admin.ThenFunc(app.adminUsers))
+	router.Handler(http.MethodPost, "/admin/users/role/:id", admin.ThenFunc(app.adminUserRolePost))
+	router.Handler(http.MethodPost, "/admin/users/disable/:id", admin.ThenFunc(app.adminUserDisablePost))
+	router.Handler(http.MethodPost, "/admin/users/delete/:id", admin.ThenFunc(app.adminUserDeletePost))
+	router.Handler(http.MethodGet, "/admin/snippets", admin.ThenFunc(app.adminSnippets))
+	router.Handler(http.MethodPost, "/admin/snippets/hide/:id", admin.ThenFunc(app.adminSnippetHidePost))
+	router.Handler(http.MethodPost, "/admin/snippets/delete/:id", admin.ThenFunc(app.adminSnippetDeletePost))
+
+	// JSON API
+	for _, rt := range app.apiRoutes() {
+		router.Handler(rt.method, rt.path, rt.handler)
+	}
+
+	// GraphQL, authenticated like the JSON API
+	graphQL := alice.New(app.sessionManager.LoadAndSave, app.expireSessions, app.authenticate, app.authenticateToken)
+	router.Handler(http.MethodPost, "/graphql", graphQL.ThenFunc(app.graphQL))
+
 	// create middleware chain via Alice convenience library
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
 
-	return standard.Then(router)
+	return standard.Then(router), router.routes
+}
+
+// apiRoutes returns the JSON API routes.
+func (app *application) apiRoutes() []route {
+	// authenticated by the same session as the HTML pages or by a personal
+	// API token
+	api := alice.New(app.sessionManager.LoadAndSave, app.expireSessions, app.authenticate, app.authenticateToken)
+	apiRead := api.Append(app.requireScope(models.ScopeSnippetsRead))
+	apiReadProtected := api.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeSnippetsRead))
+	apiWriteProtected := api.Append(app.requireAPIAuthentication, app.requireScope(models.ScopeSnippetsWrite))
+
+	return []route{
+		{http.MethodGet, "/api/openapi.json", http.HandlerFunc(app.openAPI)},
+		{http.MethodGet, "/api/v1/snippets", apiReadProtected.ThenFunc(app.apiSnippetList)},
+		{http.MethodPost, "/api/v1/snippets", apiWriteProtected.ThenFunc(app.apiSnippetCreate)},
+		{http.MethodGet, "/api/v1/snippets/:id", apiRead.ThenFunc(app.apiSnippetGet)},
+		{http.MethodPut, "/api/v1/snippets/:id", apiWriteProtected.ThenFunc(app.apiSnippetUpdate)},
+		{http.MethodDelete, "/api/v1/snippets/:id", apiWriteProtected.ThenFunc(app.apiSnippetDelete)},
+	}
+}
+
+// route is a method and path registered on the router.
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// routeRecorder is an httprouter.Router that remembers the routes registered
+// on it.  Every registration method is wrapped so that no route can be added
+// without being recorded.
+type routeRecorder struct {
+	*httprouter.Router
+	routes []route
+}
+
+func (rr *routeRecorder) record(method, path string) {
+	rr.routes = append(rr.routes, route{method: method, path: path})
+}
+
+func (rr *routeRecorder) Handle(method, path string, handle httprouter.Handle) {
+	rr.record(method, path)
+	rr.Router.Handle(method, path, handle)
+}
+
+func (rr *routeRecorder) Handler(method, path string, handler http.Handler) {
+	rr.record(method, path)
+	rr.Router.Handler(method, path, handler)
+}
+
+func (rr *routeRecorder) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	rr.record(method, path)
+	rr.Router.HandlerFunc(method, path, handler)
+}
+
+func (rr *routeRecorder) ServeFiles(path string, root http.FileSystem) {
+	rr.record(http.MethodGet, path)
+	rr.Router.ServeFiles(path, root)
+}
+
+func (rr *routeRecorder) GET(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodGet, path, handle)
+}
+
+func (rr *routeRecorder) HEAD(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodHead, path, handle)
+}
+
+func (rr *routeRecorder) OPTIONS(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodOptions, path, handle)
+}
+
+func (rr *routeRecorder) POST(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodPost, path, handle)
+}
+
+func (rr *routeRecorder) PUT(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodPut, path, handle)
+}
+
+func (rr *routeRecorder) PATCH(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodPatch, path, handle)
+}
+
+func (rr *routeRecorder) DELETE(path string, handle httprouter.Handle) {
+	rr.Handle(http.MethodDelete, path, handle)
 }
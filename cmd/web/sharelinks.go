@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/validator"
+)
+
+type shareLinkForm struct {
+	Hours               int `form:"hours"`
+	validator.Validator `form:"-"`
+}
+
+// shareLinkMAC computes the signature of a link to a snippet that is valid
+// until expires.  Both the server key and the snippet's own secret are
+// needed, so rotating the secret invalidates every link for it.
+func (app *application) shareLinkMAC(id int, secret []byte, expires int64) []byte {
+	mac := hmac.New(sha256.New, app.shareKey)
+	mac.Write(secret)
+	fmt.Fprintf(mac, ":%d:%d", id, expires)
+
+	return mac.Sum(nil)
+}
+
+// validShareLink reports whether the query of a link carries an unexpired
+// signature for the snippet.
+func (app *application) validShareLink(q url.Values, s *models.Snippet) (bool, error) {
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil || len(sig) == 0 {
+		return false, nil
+	}
+
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false, nil
+	}
+
+	secret, err := app.snippets.ShareSecret(s.ID)
+	if err != nil || secret == nil {
+		return false, err
+	}
+
+	// constant time comparison so the signature can't be guessed byte by byte
+	return hmac.Equal(sig, app.shareLinkMAC(s.ID, secret, expires)), nil
+}
+
+// snippetLinkPost creates a signed link that lets anyone read a snippet
+// for a limited time, and shows it to the owner.
+func (app *application) snippetLinkPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form shareLinkForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil || !validator.PermittedInt(form.Hours, 1, 24, 168) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	secret, err := app.snippets.ShareSecret(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if secret == nil {
+		secret, err = app.snippets.RotateShareSecret(snippet.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	expires := time.Now().Add(time.Duration(form.Hours) * time.Hour)
+	sig := base64.RawURLEncoding.EncodeToString(app.shareLinkMAC(snippet.ID, secret, expires.Unix()))
+
+	data, err := app.snippetViewData(r, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data.ShareLink = fmt.Sprintf("%s/snippet/view/%d?expires=%d&sig=%s", app.origin, snippet.ID, expires.Unix(), sig)
+	data.ShareLinkExpires = expires
+
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
+
+// snippetLinkRevokePost invalidates every share link for a snippet.
+func (app *application) snippetLinkRevokePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.requestOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := app.snippets.RotateShareSecret(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "All share links for this snippet have been revoked.")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", snippet.ID), http.StatusSeeOther)
+}
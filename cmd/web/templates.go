@@ -14,19 +14,80 @@ func humanDate(t time.Time) string {
 }
 
 type templateData struct {
-	CurrentYear     int
-	IsAuthenticated bool
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
+	CurrentYear       int
+	IsAuthenticated   bool
+	UserRole          models.Role
+	Snippet           *models.Snippet
+	Snippets          []*models.Snippet
+	Author            *models.User
+	Visibilities      []models.Visibility
+	Pagination        *pagination
+	Filter            models.SnippetFilter
+	Tags              []string
+	Sorts             []string
+	Team              *models.Team
+	Teams             []*models.Team
+	TeamMembers       []*models.TeamMember
+	TeamRoles         []models.TeamRole
+	IsOwner           bool
+	CanEdit           bool
+	Shares            []*models.SnippetShare
+	SharePermissions  []models.SharePermission
+	ShareLink         string
+	ShareLinkExpires  time.Time
+	User              *models.User
+	Users             []*models.User
+	Roles             []models.Role
+	UserStats         *models.UserStats
+	SnippetStats      *models.SnippetStats
+	Query             string
+	Form              any
+	Flash             string
+	TOTPEnabled       bool
+	TOTPURI           string
+	TOTPSecret        string
+	RecoveryCodes     []string
+	Passkeys          []*models.WebAuthnCredential
+	OIDCProviders     []oidcProviderLink
+	Sessions          []*models.UserSession
+	CurrentSession    string
+	APITokens         []*models.APIToken
+	APIScopes         []models.APIScope
+	NewAPIToken       string
+	Webhooks          []*models.Webhook
+	WebhookEvents     []models.WebhookEvent
+	WebhookDeliveries []*models.WebhookDelivery
+	NewWebhook        *models.Webhook
+	DeletionPolicy    string
+	RecentLogin       bool
+	OEmbedURL         string
+}
+
+// IsModerator reports whether the current user may moderate snippets.
+func (td *templateData) IsModerator() bool {
+	return td.UserRole.Includes(models.RoleModerator)
+}
+
+// IsAdmin reports whether the current user may manage accounts.
+func (td *templateData) IsAdmin() bool {
+	return td.UserRole.Includes(models.RoleAdmin)
+}
+
+func (app *application) authenticatedUserRole(r *http.Request) models.Role {
+	if user := app.authenticatedUser(r); user != nil {
+		return user.Role
+	}
+
+	return ""
 }
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
 		CurrentYear:     time.Now().Year(),
 		IsAuthenticated: app.isAuthenticated(r),
+		UserRole:        app.authenticatedUserRole(r),
 		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
+		OIDCProviders:   app.oidcLinks,
 	}
 }
 
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"snippetbox.mattman.net/internal/models/mocks"
+)
+
+// newTestApplication returns an application backed by the in-memory models
+// in internal/models/mocks and an in-memory session store.
+func newTestApplication(t *testing.T) *application {
+	users := &mocks.UserModel{}
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:          "localhost",
+		RPDisplayName: "Snippetbox",
+		RPOrigins:     []string{"https://localhost:4000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as configured by main
+	sessionManager := scs.New()
+	sessionManager.Cookie.Persist = false
+
+	return &application{
+		errorLog:            log.New(io.Discard, "", 0),
+		infoLog:             log.New(io.Discard, "", 0),
+		users:               users,
+		identities:          &mocks.IdentityModel{Users: users},
+		userSessions:        &mocks.UserSessionModel{},
+		webauthnCredentials: &mocks.WebAuthnModel{},
+		webhooks:            &mocks.WebhookModel{},
+		webhookClient:       newWebhookClient(true),
+		formDecoder:         form.NewDecoder(),
+		sessionManager:      sessionManager,
+		webAuthn:            webAuthn,
+		origin:              "https://localhost:4000",
+		sessionLifetime:     12 * time.Hour,
+		idleTimeout:         time.Hour,
+	}
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+// newTestServer starts a TLS server for h whose client keeps cookies and
+// does not follow redirects.
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+func (ts *testServer) postJSON(t *testing.T, urlPath string, body []byte) (int, http.Header, string) {
+	rs, err := ts.Client().Post(ts.URL+urlPath, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(b)
+}
+
+// logIn gives the client a session logged in as the user with the given ID,
+// as if they had just entered their password.
+func (ts *testServer) logIn(t *testing.T, app *application, id int) {
+	ctx, err := app.sessionManager.Load(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	app.sessionManager.Put(ctx, sessionUserIdKey, id)
+	app.sessionManager.Put(ctx, sessionLoginTimeKey, now)
+	app.sessionManager.Put(ctx, sessionLastActivityKey, now)
+
+	token, expiry, err := app.sessionManager.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Client().Jar.SetCookies(u, []*http.Cookie{
+		{Name: app.sessionManager.Cookie.Name, Value: token, Path: "/", Expires: expiry},
+	})
+}
+
+// session loads the server side of the client's current session, so tests
+// can inspect values such as the flash without rendering a page.
+func (ts *testServer) session(t *testing.T, sm *scs.SessionManager) context.Context {
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var token string
+	for _, c := range ts.Client().Jar.Cookies(u) {
+		if c.Name == sm.Cookie.Name {
+			token = c.Value
+		}
+	}
+
+	ctx, err := sm.Load(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
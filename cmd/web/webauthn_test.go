@@ -0,0 +1,274 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+// testAuthenticator is a software passkey holding an ES256 key.  It builds
+// "none" attestations and assertions by hand, the way a browser would pass
+// them on from a hardware authenticator.
+type testAuthenticator struct {
+	key        *ecdsa.PrivateKey
+	credID     []byte
+	userHandle []byte
+	signCount  uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credID := make([]byte, 16)
+	_, err = rand.Read(credID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testAuthenticator{key: key, credID: credID}
+}
+
+// testWebAuthnOptions is the part of the begin responses the authenticator
+// needs.
+type testWebAuthnOptions struct {
+	PublicKey struct {
+		Challenge string `json:"challenge"`
+		User      struct {
+			ID protocol.URLEncodedBase64 `json:"id"`
+		} `json:"user"`
+	} `json:"publicKey"`
+}
+
+func (a *testAuthenticator) clientData(t *testing.T, ceremony protocol.CeremonyType, challenge string) []byte {
+	b, err := json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": challenge,
+		"origin":    "https://localhost:4000",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func (a *testAuthenticator) authData(flags protocol.AuthenticatorFlags, attestedCredential []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte("localhost"))
+
+	b := append(rpIDHash[:], byte(flags))
+	b = binary.BigEndian.AppendUint32(b, a.signCount)
+	return append(b, attestedCredential...)
+}
+
+// register answers the options from /account/passkeys/register/begin.
+func (a *testAuthenticator) register(t *testing.T, body string) []byte {
+	var opts testWebAuthnOptions
+	err := json.Unmarshal([]byte(body), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.userHandle = opts.PublicKey.User.ID
+
+	point, err := a.key.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: point[1:33],
+		YCoord: point[33:],
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// AAGUID, credential ID length, credential ID, COSE public key
+	attested := make([]byte, 16)
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credID)))
+	attested = append(attested, a.credID...)
+	attested = append(attested, publicKey...)
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(flags, attested),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string][]byte{
+		"clientDataJSON":    a.clientData(t, protocol.CreateCeremony, opts.PublicKey.Challenge),
+		"attestationObject": attestation,
+	})
+}
+
+// login answers the options from /user/login/passkey/begin.
+func (a *testAuthenticator) login(t *testing.T, body string) []byte {
+	var opts testWebAuthnOptions
+	err := json.Unmarshal([]byte(body), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.signCount++
+	authData := a.authData(protocol.FlagUserPresent|protocol.FlagUserVerified, nil)
+	clientData := a.clientData(t, protocol.AssertCeremony, opts.PublicKey.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a.response(t, map[string][]byte{
+		"clientDataJSON":    clientData,
+		"authenticatorData": authData,
+		"signature":         signature,
+		"userHandle":        a.userHandle,
+	})
+}
+
+// response encodes a PublicKeyCredential as sent by the browser script.
+func (a *testAuthenticator) response(t *testing.T, fields map[string][]byte) []byte {
+	response := make(map[string]string)
+	for k, v := range fields {
+		response[k] = base64.RawURLEncoding.EncodeToString(v)
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(a.credID)
+	b, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	app := newTestApplication(t)
+
+	err := app.users.Insert("Alice", "alice", "alice@example.com", "pa55word")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator := newTestAuthenticator(t)
+
+	t.Run("Register", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		ts.logIn(t, app, 1)
+
+		code, _, body := ts.postJSON(t, "/account/passkeys/register/begin", nil)
+		if code != http.StatusOK {
+			t.Fatalf("begin: got status %d; want %d", code, http.StatusOK)
+		}
+
+		response := authenticator.register(t, body)
+		code, _, _ = ts.postJSON(t, "/account/passkeys/register/finish?name=Laptop", response)
+		if code != http.StatusOK {
+			t.Fatalf("finish: got status %d; want %d", code, http.StatusOK)
+		}
+
+		creds, err := app.webauthnCredentials.ForUser(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(creds) != 1 || creds[0].Name != "Laptop" {
+			t.Fatalf("got %d passkeys; want one named Laptop", len(creds))
+		}
+
+		// a ceremony can only be finished once
+		code, _, _ = ts.postJSON(t, "/account/passkeys/register/finish?name=Laptop", response)
+		if code != http.StatusBadRequest {
+			t.Errorf("replayed finish: got status %d; want %d", code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("Login", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+
+		code, _, body := ts.postJSON(t, "/user/login/passkey/begin", nil)
+		if code != http.StatusOK {
+			t.Fatalf("begin: got status %d; want %d", code, http.StatusOK)
+		}
+
+		code, _, body = ts.postJSON(t, "/user/login/passkey/finish", authenticator.login(t, body))
+		if code != http.StatusOK {
+			t.Fatalf("finish: got status %d; want %d", code, http.StatusOK)
+		}
+
+		var rs struct{ Redirect string }
+		err := json.Unmarshal([]byte(body), &rs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rs.Redirect != "/snippet/mine" {
+			t.Errorf("got redirect %q; want /snippet/mine", rs.Redirect)
+		}
+
+		session := ts.session(t, app.sessionManager)
+		if id := app.sessionManager.GetInt(session, sessionUserIdKey); id != 1 {
+			t.Errorf("logged in as user %d; want 1", id)
+		}
+
+		creds, err := app.webauthnCredentials.ForUser(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := creds[0].Credential.Authenticator.SignCount; count != authenticator.signCount {
+			t.Errorf("stored sign count %d; want %d", count, authenticator.signCount)
+		}
+		if !creds[0].LastUsed.Valid {
+			t.Error("passkey last used time was not saved")
+		}
+	})
+
+	t.Run("Login with wrong key", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+
+		code, _, body := ts.postJSON(t, "/user/login/passkey/begin", nil)
+		if code != http.StatusOK {
+			t.Fatalf("begin: got status %d; want %d", code, http.StatusOK)
+		}
+
+		impostor := newTestAuthenticator(t)
+		impostor.credID = authenticator.credID
+		impostor.userHandle = authenticator.userHandle
+		impostor.signCount = authenticator.signCount
+
+		code, _, _ = ts.postJSON(t, "/user/login/passkey/finish", impostor.login(t, body))
+		if code != http.StatusUnauthorized {
+			t.Errorf("finish: got status %d; want %d", code, http.StatusUnauthorized)
+		}
+
+		session := ts.session(t, app.sessionManager)
+		if id := app.sessionManager.GetInt(session, sessionUserIdKey); id != 0 {
+			t.Errorf("logged in as user %d; want no login", id)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+const (
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+	webhookMaxAttempts  = 8
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+// webhookPayload is the JSON body POSTed to webhooks.
+type webhookPayload struct {
+	Event   models.WebhookEvent `json:"event"`
+	Created time.Time           `json:"created"`
+	Snippet any                 `json:"snippet,omitempty"`
+}
+
+// deletedSnippet identifies a snippet in snippet.deleted events.
+type deletedSnippet struct {
+	ID int `json:"id"`
+}
+
+// queueWebhookEvent queues event for the webhooks of the snippet's owner.
+// Failures are logged rather than returned, since the change that caused the
+// event has already been made.
+func (app *application) queueWebhookEvent(userID int, event models.WebhookEvent, snippet any) {
+	if userID == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(webhookPayload{Event: event, Created: time.Now().UTC(), Snippet: snippet})
+	if err == nil {
+		err = app.webhooks.Enqueue(userID, event, payload)
+	}
+	if err != nil {
+		app.errorLog.Printf("queueing %s webhooks for user %d: %v", event, userID, err)
+	}
+}
+
+// queueSnippetEvent queues a created, updated or expired event carrying the
+// snippet's current state.
+func (app *application) queueSnippetEvent(event models.WebhookEvent, s *models.Snippet) {
+	app.queueWebhookEvent(int(s.UserID.Int64), event, newAPISnippet(s))
+}
+
+// queueSnippetEventByID is like queueSnippetEvent for a snippet that hasn't
+// been loaded yet.
+func (app *application) queueSnippetEventByID(event models.WebhookEvent, id int) {
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		app.errorLog.Printf("queueing %s webhooks for snippet %d: %v", event, id, err)
+		return
+	}
+
+	app.queueSnippetEvent(event, s)
+}
+
+// webhookSignature signs a delivery.  Receivers recompute it from the
+// timestamp and body to verify that the request came from us, and can
+// reject old timestamps to prevent replays.
+func webhookSignature(secret string, timestamp int64, body []byte) (string, error) {
+	key, err := hex.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+// webhookBackoff returns how long to wait before retrying a delivery that
+// has failed attempts times, or zero when it should be given up on.
+func webhookBackoff(attempts int) time.Duration {
+	if attempts >= webhookMaxAttempts {
+		return 0
+	}
+
+	return time.Minute << (attempts - 1)
+}
+
+// newWebhookClient returns the HTTP client deliveries are sent with.  Unless
+// allowPrivate is set, it refuses to connect to loopback, private and other
+// internal addresses, checked after DNS resolution so a public name can't
+// point at an internal host.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			if allowPrivate {
+				return nil
+			}
+
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     time.Minute,
+		},
+		// a redirect is reported as a failed delivery
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// deliverWebhook sends a delivery once and records the outcome.
+func (app *application) deliverWebhook(d *models.WebhookDelivery) {
+	var status int
+
+	err := func() error {
+		signature, err := webhookSignature(d.Secret, time.Now().Unix(), d.Payload)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Snippetbox-Webhook/1.0")
+		req.Header.Set("X-Snippetbox-Event", string(d.Event))
+		req.Header.Set("X-Snippetbox-Delivery", fmt.Sprint(d.ID))
+		req.Header.Set("X-Snippetbox-Signature", signature)
+
+		resp, err := app.webhookClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+		status = resp.StatusCode
+		if status < 200 || status > 299 {
+			return errors.New(resp.Status)
+		}
+
+		return nil
+	}()
+
+	var retryIn time.Duration
+	var errMsg string
+	if err != nil {
+		retryIn = webhookBackoff(d.Attempts + 1)
+		errMsg = err.Error()
+	}
+
+	err = app.webhooks.RecordAttempt(d.ID, err == nil, status, errMsg, retryIn)
+	if err != nil {
+		app.errorLog.Printf("recording webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// queueExpiredSnippets queues expiry events for snippets that have expired
+// since the last run.
+func (app *application) queueExpiredSnippets() error {
+	snippets, err := app.snippets.ExpiredUnnotified(webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snippets {
+		app.queueSnippetEvent(models.EventSnippetExpired, s)
+
+		if err := app.snippets.SetExpiryNotified(s.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliverWebhooks periodically sends queued webhook deliveries, several at
+// a time, and prunes the delivery log.
+func (app *application) deliverWebhooks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+
+	for ; true; <-ticker.C {
+		if err := app.queueExpiredSnippets(); err != nil {
+			app.errorLog.Printf("queueing snippet expiry webhooks: %v", err)
+		}
+
+		// the lease outlasts a full round of concurrent attempts
+		deliveries, err := app.webhooks.Claim(webhookBatchSize, 3*webhookTimeout)
+		if err != nil {
+			app.errorLog.Printf("claiming webhook deliveries: %v", err)
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, d := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				app.deliverWebhook(d)
+			}()
+		}
+		wg.Wait()
+
+		if time.Since(lastPurge) > time.Hour {
+			lastPurge = time.Now()
+
+			if _, err := app.webhooks.PurgeDeliveries(webhookLogRetention); err != nil {
+				app.errorLog.Printf("purging webhook deliveries: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+	"snippetbox.mattman.net/internal/models/mocks"
+)
+
+// testWebhookReceiver verifies the signature of each delivery the way a
+// receiver is told to, and answers with the next of its statuses.
+type testWebhookReceiver struct {
+	*httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func newTestWebhookReceiver(t *testing.T, statuses ...int) *testWebhookReceiver {
+	rcv := &testWebhookReceiver{statuses: statuses}
+
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+
+		if !validWebhookSignature(r.Header.Get("X-Snippetbox-Signature"), rcv.secret, body) {
+			t.Errorf("delivery has invalid signature %q", r.Header.Get("X-Snippetbox-Signature"))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rcv.bodies = append(rcv.bodies, string(body))
+		w.WriteHeader(rcv.statuses[0])
+		rcv.statuses = rcv.statuses[1:]
+	}))
+	t.Cleanup(rcv.Close)
+
+	return rcv
+}
+
+// validWebhookSignature checks a "t=<timestamp>,v1=<hex HMAC>" header
+// against the HMAC-SHA256 of the timestamp and body.
+func validWebhookSignature(header, secret string, body []byte) bool {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			timestamp = v
+		case "v1":
+			signature = v
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		return false
+	}
+
+	key, err := hex.DecodeString(secret)
+	if err != nil {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s.", timestamp)
+	mac.Write(body)
+
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	app := newTestApplication(t)
+	webhooks := app.webhooks.(*mocks.WebhookModel)
+
+	rcv := newTestWebhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+
+	id, err := webhooks.Insert(1, rcv.URL, []models.WebhookEvent{models.EventSnippetCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook, err := webhooks.Get(1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rcv.secret = hook.Secret
+
+	payload := `{"event":"snippet.created"}`
+	err = webhooks.Enqueue(1, models.EventSnippetCreated, []byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := webhooks.Claim(webhookBatchSize, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("claimed %d deliveries; want 1", len(deliveries))
+	}
+	d := deliveries[0]
+
+	t.Run("Server error", func(t *testing.T) {
+		start := time.Now()
+		app.deliverWebhook(d)
+
+		logged, err := webhooks.Deliveries(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := logged[0]; got.Status != models.DeliveryPending || got.Attempts != 1 || got.ResponseStatus.Int64 != http.StatusInternalServerError {
+			t.Errorf("got %s after %d attempts with response %v; want pending after 1 with 500", got.Status, got.Attempts, got.ResponseStatus)
+		}
+
+		// retried after webhookBackoff(1)
+		next := webhooks.NextAttempt(d.ID)
+		if next.Before(start.Add(time.Minute)) || next.After(time.Now().Add(time.Minute)) {
+			t.Errorf("next attempt in %v; want %v", time.Until(next).Round(time.Second), time.Minute)
+		}
+		if due, err := webhooks.Claim(webhookBatchSize, time.Minute); err != nil || len(due) != 0 {
+			t.Errorf("claimed %d deliveries (%v) before the retry was due; want 0", len(due), err)
+		}
+
+		d.Attempts = logged[0].Attempts
+	})
+
+	t.Run("Retry delivered", func(t *testing.T) {
+		app.deliverWebhook(d)
+
+		logged, err := webhooks.Deliveries(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := logged[0]; got.Status != models.DeliveryDelivered || got.Attempts != 2 || got.ResponseStatus.Int64 != http.StatusOK || got.Error != "" {
+			t.Errorf("got %s after %d attempts with response %v, error %q; want delivered after 2 with 200", got.Status, got.Attempts, got.ResponseStatus, got.Error)
+		}
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		if len(rcv.bodies) != 2 || rcv.bodies[1] != payload {
+			t.Errorf("receiver got %q; want the payload twice", rcv.bodies)
+		}
+	})
+
+	t.Run("Private address refused", func(t *testing.T) {
+		app.webhookClient = newWebhookClient(false)
+
+		d := *d
+		d.Attempts = 0
+		app.deliverWebhook(&d)
+
+		logged, err := webhooks.Deliveries(1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := logged[0]; got.ResponseStatus.Valid || !strings.Contains(got.Error, "is not allowed") {
+			t.Errorf("got response %v, error %q; want the loopback dial refused", got.ResponseStatus, got.Error)
+		}
+	})
+}
@@ -6,33 +6,218 @@ USE snippetbox;
 
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER,
   title VARCHAR(100) NOT NULL,
   content TEXT NOT NULL,
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  updated DATETIME,
+  expires DATETIME NOT NULL,
+  team_id INTEGER,
+  visibility ENUM('public', 'private', 'team') NOT NULL DEFAULT 'public',
+  hidden BOOLEAN NOT NULL DEFAULT FALSE,
+  share_secret VARBINARY(32),
+  expiry_notified BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user ON snippets(user_id, visibility, expires);
+CREATE INDEX idx_snippets_expiry_notified ON snippets(expiry_notified, expires);
 
 -- users table
 
 CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
   name VARCHAR(255) NOT NULL,
+  handle VARCHAR(30) NOT NULL,
   email VARCHAR(255) NOT NULL,
+  email_verified BOOLEAN NOT NULL DEFAULT FALSE,
   hashed_password VARCHAR(60) NOT NULL,
-  created DATETIME NOT NULL
+  created DATETIME NOT NULL,
+  role ENUM('user', 'moderator', 'admin') NOT NULL DEFAULT 'user',
+  disabled_at DATETIME,
+  deleted_at DATETIME,
+  totp_key VARBINARY(512),
+  totp_enabled BOOLEAN NOT NULL DEFAULT FALSE
 );
 
 ALTER TABLE users ADD CONSTRAINT user_uc_email UNIQUE(email);
+ALTER TABLE users ADD CONSTRAINT user_uc_handle UNIQUE(handle);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+-- pending email address changes awaiting verification
+
+CREATE TABLE email_changes (
+  token_hash CHAR(64) NOT NULL PRIMARY KEY,
+  user_id INTEGER NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  expiry DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- single-use recovery codes for two-factor authentication
+
+CREATE TABLE recovery_codes (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  hashed_code CHAR(64) NOT NULL,
+  used DATETIME,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_recovery_codes_user ON recovery_codes(user_id);
+
+-- passkeys registered via WebAuthn
+
+CREATE TABLE webauthn_credentials (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  credential_id VARBINARY(1023) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  data BLOB NOT NULL,
+  created DATETIME NOT NULL,
+  last_used DATETIME,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE webauthn_credentials ADD CONSTRAINT webauthn_uc_credential UNIQUE(credential_id);
 
--- INSERT INTO users (name, email, hashed_password, created) VALUES (
+-- accounts at external OpenID Connect providers
+
+CREATE TABLE user_identities (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  provider VARCHAR(50) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE user_identities ADD CONSTRAINT identity_uc_subject UNIQUE(provider, subject);
+
+-- teams share snippets between their members
+
+CREATE TABLE teams (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(100) NOT NULL,
+  created DATETIME NOT NULL
+);
+
+CREATE TABLE team_members (
+  team_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  role ENUM('member', 'owner') NOT NULL DEFAULT 'member',
+  joined DATETIME NOT NULL,
+  PRIMARY KEY (team_id, user_id),
+  FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_team_members_user ON team_members(user_id);
+
+CREATE TABLE team_invitations (
+  token_hash CHAR(64) NOT NULL PRIMARY KEY,
+  team_id INTEGER NOT NULL,
+  email VARCHAR(255) NOT NULL,
+  invited_by INTEGER NOT NULL,
+  expiry DATETIME NOT NULL,
+  FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE,
+  FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- snippets shared with individual users
+
+CREATE TABLE snippet_shares (
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  permission ENUM('read', 'edit') NOT NULL DEFAULT 'read',
+  created DATETIME NOT NULL,
+  PRIMARY KEY (snippet_id, user_id),
+  FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_snippet_shares_user ON snippet_shares(user_id);
+
+-- a deleted team's snippets stay with their authors
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_team
+  FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE SET NULL;
+
+-- personal access tokens for the JSON API
+
+CREATE TABLE api_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  scopes VARCHAR(255) NOT NULL,
+  created DATETIME NOT NULL,
+  expires DATETIME,
+  last_used DATETIME,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_hash UNIQUE(token_hash);
+
+-- outgoing webhooks and their delivery queue
+
+CREATE TABLE webhooks (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  url VARCHAR(2048) NOT NULL,
+  events SET('snippet.created', 'snippet.updated', 'snippet.deleted', 'snippet.expired') NOT NULL,
+  secret CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE webhook_deliveries (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  webhook_id INTEGER NOT NULL,
+  event VARCHAR(32) NOT NULL,
+  payload MEDIUMBLOB NOT NULL,
+  status ENUM('pending', 'delivered', 'failed') NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt DATETIME NOT NULL,
+  response_status INTEGER,
+  error VARCHAR(255) NOT NULL DEFAULT '',
+  created DATETIME NOT NULL,
+  last_attempt DATETIME,
+  FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt);
+
+-- free-form labels on snippets
+
+CREATE TABLE tags (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(30) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tag_uc_name UNIQUE(name);
+
+CREATE TABLE snippet_tags (
+  snippet_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (snippet_id, tag_id),
+  FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE,
+  FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+-- INSERT INTO users (name, handle, email, hashed_password, created) VALUES (
+--   'demo',
 --   'demo',
 --   'demo@example.com',
 --   '',
 --   UTC_TIMESTAMP()
 -- );
 
+-- promote the first admin manually, e.g.
+-- UPDATE users SET role = 'admin' WHERE email = 'demo@example.com';
+
 
 -- user sessions table
 
@@ -44,6 +229,22 @@ CREATE TABLE sessions (
 
 CREATE INDEX idx_sessions_expiry on sessions(expiry);
 
+-- which user a session belongs to, for listing and revoking sessions
+
+CREATE TABLE user_sessions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  token CHAR(43) NOT NULL,
+  user_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  last_seen DATETIME NOT NULL,
+  ip VARCHAR(45) NOT NULL,
+  user_agent VARCHAR(255) NOT NULL,
+  remember BOOLEAN NOT NULL DEFAULT FALSE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+ALTER TABLE user_sessions ADD CONSTRAINT user_sessions_uc_token UNIQUE(token);
+
 -- application user
 DROP USER IF EXISTS 'web'@'%';
 
@@ -87,4 +288,3 @@ INSERT INTO snippets (title, content, created, expires) VALUES (
   UTC_TIMESTAMP(),
   DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)
 );
-
@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer sends plain text email through an SMTP server.  When no Host is
+// configured messages are written to Log instead, which is convenient
+// during development.
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Sender   string
+	Log      *log.Logger
+}
+
+// Send delivers a message with the given subject and body to recipient.
+func (m *Mailer) Send(recipient, subject, body string) error {
+	if m.Host == "" {
+		m.Log.Printf("email to %s\nSubject: %s\n\n%s", recipient, subject, body)
+		return nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.Sender)
+	fmt.Fprintf(&msg, "To: %s\r\n", recipient)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.Sender, []string{recipient}, []byte(msg.String()))
+}
@@ -5,3 +5,7 @@ import "errors"
 var ErrNoRecord = errors.New("models: no matching record found")
 var ErrInvalidCredentials = errors.New("models: invalid credentials")
 var ErrDuplicateEmail = errors.New("models: duplicate email")
+var ErrDuplicateHandle = errors.New("models: duplicate handle")
+var ErrAccountDisabled = errors.New("models: account disabled")
+var ErrDuplicateCredential = errors.New("models: duplicate credential")
+var ErrLastOwner = errors.New("models: team must keep an owner")
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// IdentityModelInterface is implemented by IdentityModel and by the mock
+// used in tests.
+type IdentityModelInterface interface {
+	UserID(provider, subject string) (int, error)
+	Link(email, provider, subject string) (int, error)
+	Provision(name, handle, email, provider, subject string) (int, error)
+}
+
+// IdentityModel links users to accounts at external identity providers.
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// UserID returns the user linked to the subject at a provider, or
+// ErrNoRecord if the identity has not been seen before.
+func (m *IdentityModel) UserID(provider, subject string) (int, error) {
+	var id int
+
+	stmt := `SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`
+
+	err := m.DB.QueryRow(stmt, provider, subject).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Link associates an existing user, found by email address, with the
+// subject at a provider and returns the user ID.  Only users who have
+// verified their address are linked, since anyone can sign up with an
+// address they don't own.
+func (m *IdentityModel) Link(email, provider, subject string) (int, error) {
+	var id int
+
+	stmt := `SELECT id FROM users WHERE email = ? AND email_verified`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+
+		return 0, err
+	}
+
+	stmt = `INSERT INTO user_identities (user_id, provider, subject, created)
+	        VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, id, provider, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Provision creates a new user for an identity at a provider.  The user is
+// given a random password that is never revealed, and changing a password
+// requires the current one, so provisioned accounts can only ever log in
+// via the provider (or a passkey registered later).
+func (m *IdentityModel) Provision(name, handle, email, provider, subject string) (int, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(b)), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// the provider has verified the address
+	stmt := `INSERT INTO users (name, handle, email, email_verified, hashed_password, created)
+	         VALUES(?, ?, ?, TRUE, ?, UTC_TIMESTAMP())`
+
+	result, err := tx.Exec(stmt, name, handle, email, hashedPassword)
+	if err != nil {
+		return 0, duplicateUserError(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt = `INSERT INTO user_identities (user_id, provider, subject, created)
+	        VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(stmt, id, provider, subject)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
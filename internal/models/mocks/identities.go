@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"sync"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+// IdentityModel keeps provider identities in memory, linking and
+// provisioning the users held by Users.
+type IdentityModel struct {
+	Users *UserModel
+
+	mu         sync.Mutex
+	identities map[identity]int
+}
+
+type identity struct {
+	provider, subject string
+}
+
+func (m *IdentityModel) UserID(provider, subject string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, ok := m.identities[identity{provider, subject}]
+	if !ok {
+		return 0, models.ErrNoRecord
+	}
+
+	return id, nil
+}
+
+func (m *IdentityModel) Link(email, provider, subject string) (int, error) {
+	m.Users.mu.Lock()
+	var id int
+	for _, u := range m.Users.users {
+		if u != nil && u.Email == email && u.EmailVerified {
+			id = u.ID
+		}
+	}
+	m.Users.mu.Unlock()
+
+	if id == 0 {
+		return 0, models.ErrNoRecord
+	}
+
+	m.add(provider, subject, id)
+	return id, nil
+}
+
+func (m *IdentityModel) Provision(name, handle, email, provider, subject string) (int, error) {
+	m.Users.mu.Lock()
+	id, err := m.Users.insert(name, handle, email, "", true)
+	m.Users.mu.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+
+	m.add(provider, subject, id)
+	return id, nil
+}
+
+func (m *IdentityModel) add(provider, subject string, id int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.identities == nil {
+		m.identities = make(map[identity]int)
+	}
+	m.identities[identity{provider, subject}] = id
+}
@@ -0,0 +1,119 @@
+package mocks
+
+import (
+	"sync"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+// UserSessionModel keeps session tracking rows in memory.
+type UserSessionModel struct {
+	mu       sync.Mutex
+	sessions []*userSession
+}
+
+type userSession struct {
+	models.UserSession
+	userID int
+}
+
+func (m *UserSessionModel) Insert(token string, userID int, ip, userAgent string, remember bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	m.sessions = append(m.sessions, &userSession{
+		UserSession: models.UserSession{
+			ID:        len(m.sessions) + 1,
+			Token:     token,
+			Created:   now,
+			LastSeen:  now,
+			IP:        ip,
+			UserAgent: userAgent,
+		},
+		userID: userID,
+	})
+
+	return nil
+}
+
+func (m *UserSessionModel) Touch(token, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s != nil && s.Token == token {
+			s.LastSeen = time.Now().UTC()
+			s.IP = ip
+		}
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) Rename(oldToken, newToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s != nil && s.Token == oldToken {
+			s.Token = newToken
+		}
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) Remove(token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.sessions {
+		if s != nil && s.Token == token {
+			m.sessions[i] = nil
+		}
+	}
+
+	return nil
+}
+
+func (m *UserSessionModel) ForUser(userID int, lifetime, idle time.Duration) ([]*models.UserSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessions := make([]*models.UserSession, 0)
+	for i := len(m.sessions) - 1; i >= 0; i-- {
+		if s := m.sessions[i]; s != nil && s.userID == userID {
+			session := s.UserSession
+			sessions = append(sessions, &session)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (m *UserSessionModel) Revoke(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > len(m.sessions) || m.sessions[id-1] == nil || m.sessions[id-1].userID != userID {
+		return models.ErrNoRecord
+	}
+
+	m.sessions[id-1] = nil
+	return nil
+}
+
+func (m *UserSessionModel) RevokeOthers(userID int, keepToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.sessions {
+		if s != nil && s.userID == userID && s.Token != keepToken {
+			m.sessions[i] = nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package mocks
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+// UserModel keeps users in memory.  IDs are allocated from 1 in the order
+// users are inserted.  Provisioned users have an empty password, which
+// never matches.
+type UserModel struct {
+	mu           sync.Mutex
+	users        []*models.User
+	passwords    map[int]string
+	emailChanges map[string]emailChange
+}
+
+type emailChange struct {
+	id    int
+	email string
+}
+
+func (m *UserModel) Insert(name, handle, email, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.insert(name, handle, email, password, false)
+	return err
+}
+
+func (m *UserModel) insert(name, handle, email, password string, verified bool) (int, error) {
+	for _, u := range m.users {
+		switch {
+		case u == nil:
+			continue
+		case u.Email == email:
+			return 0, models.ErrDuplicateEmail
+		case u.Handle == handle:
+			return 0, models.ErrDuplicateHandle
+		}
+	}
+
+	u := &models.User{
+		ID:            len(m.users) + 1,
+		Name:          name,
+		Handle:        handle,
+		Email:         email,
+		EmailVerified: verified,
+		Created:       time.Now().UTC(),
+		Role:          models.RoleUser,
+	}
+	m.users = append(m.users, u)
+
+	if m.passwords == nil {
+		m.passwords = make(map[int]string)
+	}
+	m.passwords[u.ID] = password
+
+	return u.ID, nil
+}
+
+func (m *UserModel) user(id int) *models.User {
+	if id < 1 || id > len(m.users) || m.users[id-1] == nil {
+		return nil
+	}
+
+	return m.users[id-1]
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u != nil && u.Email == email && !u.DeletedAt.Valid && password != "" && m.passwords[u.ID] == password {
+			if u.DisabledAt.Valid {
+				return 0, models.ErrAccountDisabled
+			}
+			return u.ID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.user(id) != nil, nil
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.user(id)
+	if u == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	user := *u
+	return &user, nil
+}
+
+func (m *UserModel) GetMany(ids []int) ([]*models.User, error) {
+	var users []*models.User
+	for _, id := range ids {
+		if u, err := m.Get(id); err == nil {
+			users = append(users, u)
+		}
+	}
+
+	return users, nil
+}
+
+func (m *UserModel) GetByHandle(handle string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u != nil && u.Handle == handle && u.Active() {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	if err := m.CheckPassword(id, currentPassword); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.passwords[id] = newPassword
+	return nil
+}
+
+func (m *UserModel) CheckPassword(id int, password string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.user(id) == nil || password == "" || m.passwords[id] != password {
+		return models.ErrInvalidCredentials
+	}
+
+	return nil
+}
+
+func (m *UserModel) NewEmailChange(id int, email string, ttl time.Duration) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u != nil && u.Email == email && u.ID != id {
+			return "", models.ErrDuplicateEmail
+		}
+	}
+
+	if m.emailChanges == nil {
+		m.emailChanges = make(map[string]emailChange)
+	}
+
+	token := "token-" + strconv.Itoa(len(m.emailChanges)+1)
+	m.emailChanges[token] = emailChange{id: id, email: email}
+
+	return token, nil
+}
+
+func (m *UserModel) ConfirmEmailChange(token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	change, ok := m.emailChanges[token]
+	if !ok || m.user(change.id) == nil {
+		return 0, models.ErrNoRecord
+	}
+	delete(m.emailChanges, token)
+
+	u := m.user(change.id)
+	u.Email = change.email
+	u.EmailVerified = true
+
+	return u.ID, nil
+}
+
+func (m *UserModel) Search(query string, limit int) ([]*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var users []*models.User
+	for i := len(m.users) - 1; i >= 0 && len(users) < limit; i-- {
+		u := m.users[i]
+		if u != nil && (strings.Contains(u.Name, query) || strings.Contains(u.Handle, query) || strings.Contains(u.Email, query)) {
+			user := *u
+			users = append(users, &user)
+		}
+	}
+
+	return users, nil
+}
+
+func (m *UserModel) RoleUpdate(id int, role models.Role) error {
+	return m.update(id, func(u *models.User) { u.Role = role })
+}
+
+func (m *UserModel) SetDisabled(id int, disabled bool) error {
+	return m.update(id, func(u *models.User) {
+		u.DisabledAt = sql.NullTime{Time: time.Now().UTC(), Valid: disabled}
+	})
+}
+
+func (m *UserModel) SoftDelete(id int) error {
+	return m.update(id, func(u *models.User) {
+		u.DeletedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+	})
+}
+
+func (m *UserModel) Restore(id int) error {
+	return m.update(id, func(u *models.User) { u.DeletedAt = sql.NullTime{} })
+}
+
+func (m *UserModel) update(id int, f func(u *models.User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := m.user(id)
+	if u == nil {
+		return models.ErrNoRecord
+	}
+
+	f(u)
+	return nil
+}
+
+func (m *UserModel) PurgeDeleted(grace time.Duration) (int, error) {
+	return 0, nil
+}
+
+func (m *UserModel) Stats() (*models.UserStats, error) {
+	return &models.UserStats{}, nil
+}
+
+func (m *UserModel) DeletePermanently(id int, anonymize bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.user(id) == nil {
+		return models.ErrNoRecord
+	}
+
+	m.users[id-1] = nil
+	return nil
+}
@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"bytes"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"snippetbox.mattman.net/internal/models"
+)
+
+// WebAuthnModel keeps passkeys in memory.
+type WebAuthnModel struct {
+	mu    sync.Mutex
+	creds []*models.WebAuthnCredential
+}
+
+func (m *WebAuthnModel) Insert(userID int, name string, cred *webauthn.Credential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.creds {
+		if c != nil && bytes.Equal(c.Credential.ID, cred.ID) {
+			return models.ErrDuplicateCredential
+		}
+	}
+
+	m.creds = append(m.creds, &models.WebAuthnCredential{
+		ID:         len(m.creds) + 1,
+		UserID:     userID,
+		Name:       name,
+		Credential: *cred,
+		Created:    time.Now().UTC(),
+	})
+
+	return nil
+}
+
+func (m *WebAuthnModel) ForUser(userID int) ([]*models.WebAuthnCredential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	creds := make([]*models.WebAuthnCredential, 0)
+	for _, c := range m.creds {
+		if c != nil && c.UserID == userID {
+			cred := *c
+			creds = append(creds, &cred)
+		}
+	}
+
+	return creds, nil
+}
+
+func (m *WebAuthnModel) Touch(cred *webauthn.Credential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, c := range m.creds {
+		if c != nil && bytes.Equal(c.Credential.ID, cred.ID) {
+			c.Credential = *cred
+			c.LastUsed = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+	}
+
+	return nil
+}
+
+func (m *WebAuthnModel) Delete(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > len(m.creds) || m.creds[id-1] == nil || m.creds[id-1].UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	m.creds[id-1] = nil
+	return nil
+}
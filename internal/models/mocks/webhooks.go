@@ -0,0 +1,221 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"snippetbox.mattman.net/internal/models"
+)
+
+// WebhookModel keeps webhooks and their delivery queue in memory.
+type WebhookModel struct {
+	mu         sync.Mutex
+	hooks      []*webhook
+	deliveries []*delivery
+}
+
+type webhook struct {
+	models.Webhook
+	userID int
+}
+
+type delivery struct {
+	models.WebhookDelivery
+	nextAttempt time.Time
+}
+
+func (m *WebhookModel) Insert(userID int, url string, events []models.WebhookEvent) (int, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := &webhook{
+		Webhook: models.Webhook{
+			ID:      len(m.hooks) + 1,
+			URL:     url,
+			Events:  events,
+			Secret:  hex.EncodeToString(b),
+			Created: time.Now().UTC(),
+		},
+		userID: userID,
+	}
+	m.hooks = append(m.hooks, h)
+
+	return h.ID, nil
+}
+
+func (m *WebhookModel) hook(userID, id int) *webhook {
+	if id < 1 || id > len(m.hooks) || m.hooks[id-1] == nil || m.hooks[id-1].userID != userID {
+		return nil
+	}
+
+	return m.hooks[id-1]
+}
+
+func (m *WebhookModel) Get(userID, id int) (*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.hook(userID, id)
+	if h == nil {
+		return nil, models.ErrNoRecord
+	}
+
+	hook := h.Webhook
+	return &hook, nil
+}
+
+func (m *WebhookModel) ForUser(userID int) ([]*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hooks := make([]*models.Webhook, 0)
+	for _, h := range m.hooks {
+		if h != nil && h.userID == userID {
+			hook := h.Webhook
+			hooks = append(hooks, &hook)
+		}
+	}
+
+	return hooks, nil
+}
+
+func (m *WebhookModel) Delete(userID, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hook(userID, id) == nil {
+		return models.ErrNoRecord
+	}
+
+	m.hooks[id-1] = nil
+	return nil
+}
+
+func (m *WebhookModel) Enqueue(userID int, event models.WebhookEvent, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, h := range m.hooks {
+		if h != nil && h.userID == userID && slices.Contains(h.Events, event) {
+			m.enqueue(h, event, payload)
+		}
+	}
+
+	return nil
+}
+
+func (m *WebhookModel) EnqueuePing(userID, id int, payload []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h := m.hook(userID, id)
+	if h == nil {
+		return models.ErrNoRecord
+	}
+
+	m.enqueue(h, models.EventPing, payload)
+	return nil
+}
+
+func (m *WebhookModel) enqueue(h *webhook, event models.WebhookEvent, payload []byte) {
+	now := time.Now().UTC()
+	m.deliveries = append(m.deliveries, &delivery{
+		WebhookDelivery: models.WebhookDelivery{
+			ID:        len(m.deliveries) + 1,
+			WebhookID: h.ID,
+			URL:       h.URL,
+			Secret:    h.Secret,
+			Event:     event,
+			Payload:   payload,
+			Status:    models.DeliveryPending,
+			Created:   now,
+		},
+		nextAttempt: now,
+	})
+}
+
+func (m *WebhookModel) Claim(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for _, d := range m.deliveries {
+		if len(deliveries) == limit {
+			break
+		}
+		if d.Status == models.DeliveryPending && !d.nextAttempt.After(now) {
+			d.nextAttempt = now.Add(lease)
+			claimed := d.WebhookDelivery
+			deliveries = append(deliveries, &claimed)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (m *WebhookModel) RecordAttempt(id int, delivered bool, status int, errMsg string, retryIn time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 1 || id > len(m.deliveries) {
+		return models.ErrNoRecord
+	}
+	d := m.deliveries[id-1]
+
+	switch {
+	case delivered:
+		d.Status = models.DeliveryDelivered
+	case retryIn == 0:
+		d.Status = models.DeliveryFailed
+	default:
+		d.Status = models.DeliveryPending
+	}
+
+	now := time.Now().UTC()
+	d.Attempts++
+	d.ResponseStatus = sql.NullInt64{Int64: int64(status), Valid: status != 0}
+	d.Error = errMsg
+	d.LastAttempt = sql.NullTime{Time: now, Valid: true}
+	d.nextAttempt = now.Add(retryIn)
+
+	return nil
+}
+
+// NextAttempt returns when a pending delivery is next due, so tests can
+// check the retry backoff.
+func (m *WebhookModel) NextAttempt(id int) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deliveries[id-1].nextAttempt
+}
+
+func (m *WebhookModel) Deliveries(userID, limit int) ([]*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for i := len(m.deliveries) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		d := m.deliveries[i]
+		if h := m.hooks[d.WebhookID-1]; h != nil && h.userID == userID {
+			delivery := d.WebhookDelivery
+			deliveries = append(deliveries, &delivery)
+		}
+	}
+
+	return deliveries, nil
+}
+
+func (m *WebhookModel) PurgeDeliveries(age time.Duration) (int, error) {
+	return 0, nil
+}
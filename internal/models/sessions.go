@@ -0,0 +1,197 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// UserSession describes a logged in session of a user.  The session data
+// itself lives in the sessions table managed by scs; this only tracks which
+// user a session token belongs to and where it is being used from.
+type UserSession struct {
+	ID        int
+	Token     string
+	Created   time.Time
+	LastSeen  time.Time
+	IP        string
+	UserAgent string
+}
+
+// UserSessionModelInterface is implemented by UserSessionModel and by the
+// mock used in tests.
+type UserSessionModelInterface interface {
+	Insert(token string, userID int, ip, userAgent string, remember bool) error
+	Touch(token, ip string) error
+	Rename(oldToken, newToken string) error
+	Remove(token string) error
+	ForUser(userID int, lifetime, idle time.Duration) ([]*UserSession, error)
+	Revoke(userID, id int) error
+	RevokeOthers(userID int, keepToken string) error
+}
+
+type UserSessionModel struct {
+	DB *sql.DB
+}
+
+// Insert starts tracking a session token for a user.  remember records
+// whether the user asked to stay logged in, see ForUser.
+func (m *UserSessionModel) Insert(token string, userID int, ip, userAgent string, remember bool) error {
+	stmt := `INSERT INTO user_sessions (token, user_id, created, last_seen, ip, user_agent, remember)
+	         VALUES (?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?, LEFT(?, 255), ?)
+	         ON DUPLICATE KEY UPDATE user_id = VALUES(user_id), last_seen = VALUES(last_seen),
+	           remember = VALUES(remember)`
+
+	_, err := m.DB.Exec(stmt, token, userID, ip, userAgent, remember)
+	return err
+}
+
+// Touch records activity on a session.  Updates are throttled to once a
+// minute to avoid a write on every request.
+func (m *UserSessionModel) Touch(token, ip string) error {
+	stmt := `UPDATE user_sessions SET last_seen = UTC_TIMESTAMP(), ip = ?
+	         WHERE token = ? AND last_seen < DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE)`
+
+	_, err := m.DB.Exec(stmt, ip, token)
+	return err
+}
+
+// Rename follows a session whose token has been renewed.
+func (m *UserSessionModel) Rename(oldToken, newToken string) error {
+	stmt := `UPDATE user_sessions SET token = ? WHERE token = ?`
+
+	_, err := m.DB.Exec(stmt, newToken, oldToken)
+	return err
+}
+
+// Remove stops tracking a session token, e.g. after logout.
+func (m *UserSessionModel) Remove(token string) error {
+	_, err := m.DB.Exec(`DELETE FROM user_sessions WHERE token = ?`, token)
+	return err
+}
+
+// ForUser returns the unexpired sessions of a user, most recently used first.
+// Every session is stored for as long as a remembered one may last, so
+// ordinary sessions that have outlived lifetime since login, or idle since
+// they were last seen, are destroyed here rather than waiting to be expired
+// on their next request.  Tracking rows for sessions that no longer exist
+// are cleaned up.
+func (m *UserSessionModel) ForUser(userID int, lifetime, idle time.Duration) ([]*UserSession, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	expired := `us.user_id = ? AND NOT us.remember
+	            AND (us.created < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	              OR us.last_seen < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	stmt := `DELETE s FROM sessions s
+	         JOIN user_sessions us ON us.token = s.token
+	         WHERE ` + expired
+
+	_, err = tx.Exec(stmt, userID, int(lifetime.Seconds()), int(idle.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	stmt = `DELETE us FROM user_sessions us
+	        LEFT JOIN sessions s ON s.token = us.token AND UTC_TIMESTAMP(6) < s.expiry
+	        WHERE us.user_id = ? AND s.token IS NULL`
+
+	_, err = tx.Exec(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	stmt = `SELECT id, token, created, last_seen, ip, user_agent FROM user_sessions
+	        WHERE user_id = ? ORDER BY last_seen DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*UserSession, 0)
+
+	for rows.Next() {
+		var s UserSession
+		err := rows.Scan(&s.ID, &s.Token, &s.Created, &s.LastSeen, &s.IP, &s.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Revoke destroys a single session of a user.  ErrNoRecord is returned if
+// the session does not belong to the user.
+func (m *UserSessionModel) Revoke(userID, id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var token string
+
+	stmt := `SELECT token FROM user_sessions WHERE id = ? AND user_id = ?`
+
+	err = tx.QueryRow(stmt, id, userID).Scan(&token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM sessions WHERE token = ?`, token); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM user_sessions WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeOthers destroys every session of a user except the one identified
+// by keepToken.  Pass an empty keepToken to destroy all of them.
+func (m *UserSessionModel) RevokeOthers(userID int, keepToken string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE s FROM sessions s
+	         JOIN user_sessions us ON us.token = s.token
+	         WHERE us.user_id = ? AND us.token <> ?`
+
+	if _, err = tx.Exec(stmt, userID, keepToken); err != nil {
+		return err
+	}
+
+	stmt = `DELETE FROM user_sessions WHERE user_id = ? AND token <> ?`
+
+	if _, err = tx.Exec(stmt, userID, keepToken); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SharePermission is the access a share grants to a snippet.
+type SharePermission string
+
+const (
+	ShareRead SharePermission = "read"
+	ShareEdit SharePermission = "edit"
+)
+
+// SharePermissions lists every permission from least to most privileged.
+var SharePermissions = []SharePermission{ShareRead, ShareEdit}
+
+// Valid reports whether p is a known permission.
+func (p SharePermission) Valid() bool {
+	return p == ShareRead || p == ShareEdit
+}
+
+// SnippetShare grants a user access to someone else's snippet.
+type SnippetShare struct {
+	SnippetID  int
+	UserID     int
+	Name       string
+	Handle     string
+	Email      string
+	Permission SharePermission
+	Created    time.Time
+}
+
+// ShareModel manages access to snippets granted to individual users.
+type ShareModel struct {
+	DB *sql.DB
+}
+
+// Grant shares a snippet with the active user who has the given email
+// address, replacing any existing permission.  ErrNoRecord is returned if
+// there is no such user.
+func (m *ShareModel) Grant(snippetID int, email string, permission SharePermission) error {
+	var userID int
+
+	stmt := `SELECT id FROM users WHERE email = ? AND disabled_at IS NULL AND deleted_at IS NULL`
+
+	err := m.DB.QueryRow(stmt, email).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_shares (snippet_id, user_id, permission, created)
+	        VALUES (?, ?, ?, UTC_TIMESTAMP())
+	        ON DUPLICATE KEY UPDATE permission = VALUES(permission)`
+
+	_, err = m.DB.Exec(stmt, snippetID, userID, permission)
+	return err
+}
+
+// Revoke removes a user's access to a snippet.
+func (m *ShareModel) Revoke(snippetID, userID int) error {
+	stmt := `DELETE FROM snippet_shares WHERE snippet_id = ? AND user_id = ?`
+
+	_, err := m.DB.Exec(stmt, snippetID, userID)
+	return err
+}
+
+// Permission returns the access a user has been granted to a snippet, or
+// an empty permission if it hasn't been shared with them.
+func (m *ShareModel) Permission(snippetID, userID int) (SharePermission, error) {
+	var permission SharePermission
+
+	stmt := `SELECT permission FROM snippet_shares WHERE snippet_id = ? AND user_id = ?`
+
+	err := m.DB.QueryRow(stmt, snippetID, userID).Scan(&permission)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	return permission, nil
+}
+
+// ForSnippet lists who a snippet has been shared with.
+func (m *ShareModel) ForSnippet(snippetID int) ([]*SnippetShare, error) {
+	stmt := `SELECT ss.snippet_id, u.id, u.name, u.handle, u.email, ss.permission, ss.created
+	         FROM snippet_shares ss JOIN users u ON u.id = ss.user_id
+	         WHERE ss.snippet_id = ? ORDER BY u.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]*SnippetShare, 0)
+
+	for rows.Next() {
+		var s SnippetShare
+		err := rows.Scan(&s.SnippetID, &s.UserID, &s.Name, &s.Handle, &s.Email, &s.Permission, &s.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// SharedWith returns a page of the unexpired snippets shared with a user
+// that haven't been hidden, most recently shared first.
+func (m *ShareModel) SharedWith(userID, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT s.id, s.user_id, s.title, s.created, s.expires FROM snippets s
+	         JOIN snippet_shares ss ON ss.snippet_id = s.id
+	         WHERE ss.user_id = ? AND NOT s.hidden AND s.expires > UTC_TIMESTAMP()
+	         ORDER BY ss.created DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.Title, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
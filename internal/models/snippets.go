@@ -1,17 +1,62 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
 	"time"
 )
 
+// Visibility determines who besides the owner may read a snippet.
+type Visibility string
+
+const (
+	VisibilityPublic  Visibility = "public"
+	VisibilityPrivate Visibility = "private"
+	VisibilityTeam    Visibility = "team" // members of the snippet's team
+)
+
+// Visibilities lists every visibility, in the order offered to users.
+var Visibilities = []Visibility{VisibilityPublic, VisibilityPrivate, VisibilityTeam}
+
+// activeOwner restricts a query on snippets to those whose owner has not
+// been disabled or deleted.  Anonymized snippets have no owner and are kept.
+const activeOwner = `(user_id IS NULL OR user_id NOT IN
+	(SELECT id FROM users WHERE deleted_at IS NOT NULL OR disabled_at IS NOT NULL))`
+
+// Valid reports whether v is a known visibility.
+func (v Visibility) Valid() bool {
+	for _, vis := range Visibilities {
+		if v == vis {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	UserID     sql.NullInt64 // owner, NULL for snippets that predate accounts
+	TeamID     sql.NullInt64 // team sharing the snippet, if visibility is team
+	Title      string
+	Content    string
+	Created    time.Time
+	Updated    sql.NullTime // last edited, NULL if never edited
+	Expires    time.Time
+	Visibility Visibility
+	Hidden     bool // hidden by a moderator
+	Tags       []string
+}
+
+// Modified returns when the snippet was last edited, or created if it never
+// has been.
+func (s *Snippet) Modified() time.Time {
+	if s.Updated.Valid {
+		return s.Updated.Time
+	}
+
+	return s.Created
 }
 
 // TODO: change this to "repo"
@@ -19,11 +64,20 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-           VALUES (?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+// Insert creates a snippet.  teamID is only recorded for team visibility.
+func (m *SnippetModel) Insert(userID int, title string, content string, expires int, visibility Visibility, teamID int, tags []string) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	team := sql.NullInt64{Int64: int64(teamID), Valid: visibility == VisibilityTeam}
 
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	stmt := `INSERT INTO snippets (user_id, team_id, title, content, created, expires, visibility)
+           VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?)`
+
+	result, err := tx.Exec(stmt, userID, team, title, content, expires, visibility)
 	if err != nil {
 		return 0, err
 	}
@@ -34,18 +88,26 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 		return 0, err
 	}
 
+	if err = setTags(tx, int(id), tags); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
 	return int(id), nil
 }
 
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden FROM snippets
 	WHERE id = ? AND expires > UTC_TIMESTAMP()`
 
 	// note: could simplify this by using DB.QueryRow(...).Scan(...) in single line
 	row := m.DB.QueryRow(stmt, id)
 	s := Snippet{}
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -55,15 +117,36 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		return nil, err
 	}
 
+	if err = m.attachTags(&s); err != nil {
+		return nil, err
+	}
+
 	return &s, nil
 }
 
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
+// Latest returns up to limit of the newest public snippets of active users,
+// optionally only those owned by userID or carrying tag when they are
+// non-zero.
+func (m *SnippetModel) Latest(userID int, tag string, limit int) ([]*Snippet, error) {
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden from snippets
+           WHERE expires > UTC_TIMESTAMP() AND visibility = 'public' AND NOT hidden AND ` + activeOwner
+	args := []any{}
+
+	if userID != 0 {
+		stmt += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+
+	if tag != "" {
+		stmt += ` AND id IN (SELECT st.snippet_id FROM snippet_tags st
+		          JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, tag)
+	}
 
-	stmt := `SELECT id, title, content, created, expires from snippets
-           WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
 
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +156,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	for rows.Next() {
 		var s Snippet
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
 		if err != nil {
 			return nil, err
 		}
@@ -86,5 +169,587 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		return nil, err
 	}
 
+	if err = m.attachTags(snippets...); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// LatestID returns the ID of the newest snippet, or zero if there are none.
+func (m *SnippetModel) LatestID() (int, error) {
+	var id int
+
+	stmt := `SELECT COALESCE(MAX(id), 0) FROM snippets`
+
+	err := m.DB.QueryRow(stmt).Scan(&id)
+	return id, err
+}
+
+// PublicAfter returns up to limit of the public snippets of active users
+// created after the snippet with ID afterID, oldest first, optionally only
+// those carrying tag.
+func (m *SnippetModel) PublicAfter(afterID int, tag string, limit int) ([]*Snippet, error) {
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden FROM snippets
+	         WHERE id > ? AND expires > UTC_TIMESTAMP() AND visibility = 'public' AND NOT hidden
+	         AND ` + activeOwner
+	args := []any{afterID}
+
+	if tag != "" {
+		stmt += ` AND id IN (SELECT st.snippet_id FROM snippet_tags st
+		          JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, tag)
+	}
+
+	stmt += ` ORDER BY id ASC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = m.attachTags(snippets...); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Update replaces the title, content and tags of a snippet.
+func (m *SnippetModel) Update(id int, title, content string, tags []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+
+	if _, err = tx.Exec(stmt, title, content, id); err != nil {
+		return err
+	}
+
+	if err = setTags(tx, id, tags); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ShareSecret returns the secret used to sign share links for a snippet, or
+// nil if none has been generated yet.
+func (m *SnippetModel) ShareSecret(id int) ([]byte, error) {
+	var secret []byte
+
+	stmt := `SELECT share_secret FROM snippets WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&secret)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// RotateShareSecret replaces the share link secret of a snippet with a new
+// random one, invalidating all existing links, and returns it.
+func (m *SnippetModel) RotateShareSecret(id int) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	stmt := `UPDATE snippets SET share_secret = ? WHERE id = ?`
+
+	if _, err := m.DB.Exec(stmt, secret, id); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// SetHidden hides a snippet from everyone but moderators, or reveals it
+// again.  ErrNoRecord is returned if the snippet doesn't exist.
+func (m *SnippetModel) SetHidden(id int, hidden bool) error {
+	stmt := `UPDATE snippets SET hidden = ? WHERE id = ?`
+
+	result, err := m.DB.Exec(stmt, hidden, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// MySQL counts only changed rows, so the snippet may already have been
+	// hidden (or visible)
+	var exists bool
+
+	err = m.DB.QueryRow(`SELECT EXISTS(SELECT true FROM snippets WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Search returns up to limit snippets whose title or content contains query,
+// newest first, including expired and hidden snippets.
+func (m *SnippetModel) Search(query string, limit int) ([]*Snippet, error) {
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden FROM snippets
+	         WHERE title LIKE ? OR content LIKE ? ORDER BY id DESC LIMIT ?`
+
+	pattern := "%" + escapeLike(query) + "%"
+
+	rows, err := m.DB.Query(stmt, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Delete permanently removes a snippet, returning the ID of its owner, or
+// zero if it had none or didn't exist.
+func (m *SnippetModel) Delete(id int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var owner sql.NullInt64
+
+	err = tx.QueryRow(`SELECT user_id FROM snippets WHERE id = ? FOR UPDATE`, id).Scan(&owner)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM snippets WHERE id = ?`, id); err != nil {
+		return 0, err
+	}
+
+	return int(owner.Int64), tx.Commit()
+}
+
+// SnippetStats summarizes the snippets for the admin dashboard, grouping
+// unexpired snippets by how soon they expire.
+type SnippetStats struct {
+	Total         int
+	Expired       int
+	ExpiresInDay  int
+	ExpiresInWeek int // after a day but within a week
+	ExpiresLater  int
+	Hidden        int
+}
+
+func (m *SnippetModel) Stats() (*SnippetStats, error) {
+	var s SnippetStats
+
+	stmt := `SELECT COUNT(*),
+	                COALESCE(SUM(expires <= UTC_TIMESTAMP()), 0),
+	                COALESCE(SUM(expires > UTC_TIMESTAMP() AND expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY)), 0),
+	                COALESCE(SUM(expires > DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 DAY) AND expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)), 0),
+	                COALESCE(SUM(expires > DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)), 0),
+	                COALESCE(SUM(hidden), 0)
+	         FROM snippets`
+
+	err := m.DB.QueryRow(stmt).Scan(&s.Total, &s.Expired, &s.ExpiresInDay, &s.ExpiresInWeek, &s.ExpiresLater, &s.Hidden)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// ForUser returns every snippet owned by a user, including expired and
+// hidden snippets, oldest first, with their tags.
+func (m *SnippetModel) ForUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden FROM snippets
+	         WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = m.attachTags(snippets...); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// PublicForUser returns a page of a user's public, unexpired snippets that
+// haven't been hidden, newest first.  There are none while the user is
+// disabled or deleted.
+func (m *SnippetModel) PublicForUser(userID, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT id, title, created, expires FROM snippets
+	         WHERE user_id = ? AND visibility = 'public' AND NOT hidden AND expires > UTC_TIMESTAMP()
+	         AND ` + activeOwner + `
+	         ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.Title, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Sort orders accepted by SnippetFilter, the first being the default.
+var SnippetSorts = []string{"newest", "oldest", "title", "expires"}
+
+var snippetSortOrder = map[string]string{
+	"newest":  "id DESC",
+	"oldest":  "id ASC",
+	"title":   "title ASC, id DESC",
+	"expires": "expires ASC, id DESC",
+}
+
+const (
+	ExpiryActive  = "active"
+	ExpiryExpired = "expired"
+)
+
+// SnippetFilter narrows down and orders the snippets listed by Filter.
+// Zero values match everything.
+type SnippetFilter struct {
+	Tag        string
+	Visibility Visibility
+	Expiry     string // ExpiryActive or ExpiryExpired
+	Sort       string // one of SnippetSorts
+}
+
+// Filter returns a page of the snippets owned by a user, including expired,
+// private and hidden snippets, with their tags.
+func (m *SnippetModel) Filter(userID int, f SnippetFilter, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT id, user_id, team_id, title, content, created, updated, expires, visibility, hidden FROM snippets
+	         WHERE user_id = ?`
+	args := []any{userID}
+
+	if f.Tag != "" {
+		stmt += ` AND id IN (SELECT st.snippet_id FROM snippet_tags st
+		          JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, f.Tag)
+	}
+
+	if f.Visibility != "" {
+		stmt += ` AND visibility = ?`
+		args = append(args, f.Visibility)
+	}
+
+	switch f.Expiry {
+	case ExpiryActive:
+		stmt += ` AND expires > UTC_TIMESTAMP()`
+	case ExpiryExpired:
+		stmt += ` AND expires <= UTC_TIMESTAMP()`
+	}
+
+	order, ok := snippetSortOrder[f.Sort]
+	if !ok {
+		order = snippetSortOrder[SnippetSorts[0]]
+	}
+
+	stmt += ` ORDER BY ` + order + ` LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = m.attachTags(snippets...); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Tags returns the names of all tags used on a user's snippets.
+func (m *SnippetModel) Tags(userID int) ([]string, error) {
+	stmt := `SELECT DISTINCT t.name FROM tags t
+	         JOIN snippet_tags st ON st.tag_id = t.id
+	         JOIN snippets s ON s.id = st.snippet_id
+	         WHERE s.user_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// Extend pushes back the expiry of a user's snippets by days, counting from
+// now for snippets that have already expired.  Snippets owned by anyone
+// else are ignored.  It returns the number of snippets extended.
+func (m *SnippetModel) Extend(userID int, ids []int, days int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	stmt := `UPDATE snippets SET expires = DATE_ADD(GREATEST(expires, UTC_TIMESTAMP()), INTERVAL ? DAY),
+	         expiry_notified = FALSE
+	         WHERE user_id = ? AND id IN (` + placeholders(len(ids)) + `)`
+
+	args := []any{days, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	result, err := m.DB.Exec(stmt, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteOwned permanently removes those of ids that belong to a user and
+// returns the IDs of the snippets deleted.
+func (m *SnippetModel) DeleteOwned(userID int, ids []int) ([]int, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	args := []any{userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	stmt := `SELECT id FROM snippets WHERE user_id = ? AND id IN (` + placeholders(len(ids)) + `) FOR UPDATE`
+
+	rows, err := tx.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deleted := make([]int, 0, len(ids))
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+
+		deleted = append(deleted, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stmt = `DELETE FROM snippets WHERE user_id = ? AND id IN (` + placeholders(len(ids)) + `)`
+
+	if _, err = tx.Exec(stmt, args...); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// ExpiredUnnotified returns up to limit snippets that have expired since
+// their owner subscribed a webhook to expiry events, and that haven't yet
+// been marked with SetExpiryNotified.
+func (m *SnippetModel) ExpiredUnnotified(limit int) ([]*Snippet, error) {
+	stmt := `SELECT s.id, s.user_id, s.team_id, s.title, s.content, s.created, s.updated, s.expires, s.visibility, s.hidden
+	         FROM snippets s
+	         WHERE NOT s.expiry_notified AND s.expires <= UTC_TIMESTAMP()
+	         AND EXISTS (SELECT 1 FROM webhooks w WHERE w.user_id = s.user_id
+	                     AND FIND_IN_SET('snippet.expired', w.events) AND w.created < s.expires)
+	         ORDER BY s.expires LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.UserID, &s.TeamID, &s.Title, &s.Content, &s.Created, &s.Updated, &s.Expires, &s.Visibility, &s.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = m.attachTags(snippets...); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// SetExpiryNotified records that an expiry event has been sent for a
+// snippet.
+func (m *SnippetModel) SetExpiryNotified(id int) error {
+	_, err := m.DB.Exec(`UPDATE snippets SET expiry_notified = TRUE WHERE id = ?`, id)
+	return err
+}
+
+// ForTeam returns a page of the unexpired snippets shared with a team that
+// haven't been hidden, newest first.
+func (m *SnippetModel) ForTeam(teamID, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT id, title, created, expires FROM snippets
+	         WHERE team_id = ? AND visibility = 'team' AND NOT hidden AND expires > UTC_TIMESTAMP()
+	         ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, teamID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make([]*Snippet, 0)
+
+	for rows.Next() {
+		var s Snippet
+		err := rows.Scan(&s.ID, &s.Title, &s.Created, &s.Expires)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, &s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return snippets, nil
 }
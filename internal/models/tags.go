@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// setTags replaces the tags on a snippet, creating any tags that don't
+// exist yet.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// LAST_INSERT_ID(id) makes the existing ID available when the tag is
+		// already present
+		stmt := `INSERT INTO tags (name) VALUES (?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`
+
+		result, err := tx.Exec(stmt, tag)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT IGNORE INTO snippet_tags (snippet_id, tag_id) VALUES (?, ?)`
+
+		if _, err = tx.Exec(stmt, snippetID, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attachTags loads the tags of each snippet in a single query.
+func (m *SnippetModel) attachTags(snippets ...*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Snippet, len(snippets))
+	args := make([]any, 0, len(snippets))
+	for _, s := range snippets {
+		byID[s.ID] = s
+		args = append(args, s.ID)
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+	         JOIN tags t ON t.id = st.tag_id
+	         WHERE st.snippet_id IN (` + placeholders(len(args)) + `) ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// placeholders returns n comma separated query placeholders for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,345 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// TeamRole determines what a member may do within a team.
+type TeamRole string
+
+const (
+	TeamRoleMember TeamRole = "member"
+	TeamRoleOwner  TeamRole = "owner"
+)
+
+// TeamRoles lists every team role from least to most privileged.
+var TeamRoles = []TeamRole{TeamRoleMember, TeamRoleOwner}
+
+// Valid reports whether r is a known team role.
+func (r TeamRole) Valid() bool {
+	return r == TeamRoleMember || r == TeamRoleOwner
+}
+
+type Team struct {
+	ID      int
+	Name    string
+	Created time.Time
+	Role    TeamRole // of the user the team was looked up for
+}
+
+type TeamMember struct {
+	UserID int
+	Name   string
+	Handle string
+	Email  string
+	Role   TeamRole
+	Joined time.Time
+}
+
+// TeamModel manages teams, their members and invitations to join them.
+type TeamModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a team with the given user as its only owner.
+func (m *TeamModel) Insert(name string, ownerID int) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO teams (name, created) VALUES (?, UTC_TIMESTAMP())`, name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO team_members (team_id, user_id, role, joined)
+	         VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = tx.Exec(stmt, id, ownerID, TeamRoleOwner)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get returns a team as seen by a user, or ErrNoRecord if the user is not a
+// member of it.
+func (m *TeamModel) Get(id, userID int) (*Team, error) {
+	var t Team
+
+	stmt := `SELECT t.id, t.name, t.created, tm.role FROM teams t
+	         JOIN team_members tm ON tm.team_id = t.id
+	         WHERE t.id = ? AND tm.user_id = ?`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&t.ID, &t.Name, &t.Created, &t.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// ForUser returns the teams a user belongs to, by name.
+func (m *TeamModel) ForUser(userID int) ([]*Team, error) {
+	stmt := `SELECT t.id, t.name, t.created, tm.role FROM teams t
+	         JOIN team_members tm ON tm.team_id = t.id
+	         WHERE tm.user_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := make([]*Team, 0)
+
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.Name, &t.Created, &t.Role); err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+// IsMember reports whether a user belongs to a team.
+func (m *TeamModel) IsMember(id, userID int) (bool, error) {
+	var member bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM team_members WHERE team_id = ? AND user_id = ?)`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&member)
+	return member, err
+}
+
+// Members lists the members of a team, owners first.
+func (m *TeamModel) Members(id int) ([]*TeamMember, error) {
+	stmt := `SELECT u.id, u.name, u.handle, u.email, tm.role, tm.joined FROM team_members tm
+	         JOIN users u ON u.id = tm.user_id
+	         WHERE tm.team_id = ? ORDER BY tm.role = 'owner' DESC, u.name`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]*TeamMember, 0)
+
+	for rows.Next() {
+		var tm TeamMember
+		err := rows.Scan(&tm.UserID, &tm.Name, &tm.Handle, &tm.Email, &tm.Role, &tm.Joined)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &tm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMemberRole changes the role of a member.  ErrNoRecord is returned if
+// the user isn't a member, and ErrLastOwner rather than leave the team
+// without an owner.
+func (m *TeamModel) SetMemberRole(id, userID int, role TeamRole) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, owners, err := lockMembers(tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if current == role {
+		return nil
+	}
+
+	if current == TeamRoleOwner && owners <= 1 {
+		return ErrLastOwner
+	}
+
+	stmt := `UPDATE team_members SET role = ? WHERE team_id = ? AND user_id = ?`
+
+	if _, err = tx.Exec(stmt, role, id, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember takes a user out of a team.  ErrNoRecord is returned if the
+// user isn't a member, and ErrLastOwner rather than leave the team without
+// an owner.
+func (m *TeamModel) RemoveMember(id, userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, owners, err := lockMembers(tx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if current == TeamRoleOwner && owners <= 1 {
+		return ErrLastOwner
+	}
+
+	_, err = tx.Exec(`DELETE FROM team_members WHERE team_id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockMembers locks the membership rows of a team until tx ends, so that
+// two owners can't demote or remove each other at the same time, and
+// returns the role of userID along with the number of owners.  ErrNoRecord
+// is returned if the user isn't a member.
+func lockMembers(tx *sql.Tx, id, userID int) (TeamRole, int, error) {
+	stmt := `SELECT user_id, role FROM team_members WHERE team_id = ? FOR UPDATE`
+
+	rows, err := tx.Query(stmt, id)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rows.Close()
+
+	var current TeamRole
+	var owners int
+
+	for rows.Next() {
+		var memberID int
+		var role TeamRole
+		if err := rows.Scan(&memberID, &role); err != nil {
+			return "", 0, err
+		}
+
+		if memberID == userID {
+			current = role
+		}
+		if role == TeamRoleOwner {
+			owners++
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return "", 0, err
+	}
+
+	if current == "" {
+		return "", 0, ErrNoRecord
+	}
+
+	return current, owners, nil
+}
+
+// Delete removes a team.  Its snippets are kept by their authors, but are
+// no longer visible to former members.
+func (m *TeamModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM teams WHERE id = ?`, id)
+	return err
+}
+
+// Invite records an invitation for an email address to join a team and
+// returns the token that must be presented to accept it.
+func (m *TeamModel) Invite(id int, email string, invitedBy int, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	stmt := `INSERT INTO team_invitations (token_hash, team_id, email, invited_by, expiry)
+	         VALUES (?, ?, ?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err := m.DB.Exec(stmt, hashToken(token), id, email, invitedBy, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// AcceptInvitation adds a user to the team they were invited to and returns
+// its ID.  The invitation must have been sent to the user's current email
+// address; ErrNoRecord is returned for unknown, expired or mismatched
+// invitations.
+func (m *TeamModel) AcceptInvitation(token string, userID int) (int, error) {
+	var id int
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT ti.team_id FROM team_invitations ti
+	         JOIN users u ON u.email = ti.email
+	         WHERE ti.token_hash = ? AND ti.expiry > UTC_TIMESTAMP() AND u.id = ?`
+
+	err = tx.QueryRow(stmt, hashToken(token), userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+
+		return 0, err
+	}
+
+	// accepting twice leaves an existing role alone
+	stmt = `INSERT IGNORE INTO team_members (team_id, user_id, role, joined)
+	        VALUES (?, ?, ?, UTC_TIMESTAMP())`
+
+	if _, err = tx.Exec(stmt, id, userID, TeamRoleMember); err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM team_invitations WHERE token_hash = ?`, hashToken(token))
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
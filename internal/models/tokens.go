@@ -0,0 +1,184 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// APIScope limits what an API token may be used for.
+type APIScope string
+
+const (
+	ScopeSnippetsRead  APIScope = "snippets:read"
+	ScopeSnippetsWrite APIScope = "snippets:write"
+)
+
+// APIScopes lists every scope a token can be granted.
+var APIScopes = []APIScope{ScopeSnippetsRead, ScopeSnippetsWrite}
+
+func (s APIScope) Valid() bool {
+	for _, scope := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiTokenPrefix makes tokens easy to recognize, e.g. by secret scanners.
+const apiTokenPrefix = "sbx_"
+
+// APIToken is a personal access token for non-browser clients.  Only a hash
+// of the token itself is stored.
+type APIToken struct {
+	ID       int
+	Name     string
+	Scopes   []APIScope
+	Created  time.Time
+	Expires  sql.NullTime // never expires when NULL
+	LastUsed sql.NullTime
+}
+
+// HasScope reports whether the token grants scope.
+func (t *APIToken) HasScope(scope APIScope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type APITokenModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a token for a user and returns its plaintext value, which
+// can't be recovered later.  A zero ttl creates a token that never expires.
+func (m *APITokenModel) Insert(userID int, name string, scopes []APIScope, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	expires := sql.NullTime{Time: time.Now().UTC().Add(ttl), Valid: ttl > 0}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, token_hash, scopes, created, expires)
+	         VALUES (?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
+
+	_, err := m.DB.Exec(stmt, userID, name, hashToken(token), joinScopes(scopes), expires)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate returns the ID of the user a token belongs to and the token
+// details.  ErrNoRecord is returned for unknown or expired tokens.
+func (m *APITokenModel) Authenticate(token string) (int, *APIToken, error) {
+	var userID int
+	var t APIToken
+	var scopes string
+
+	stmt := `SELECT user_id, id, name, scopes, created, expires, last_used FROM api_tokens
+	         WHERE token_hash = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP())`
+
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&userID, &t.ID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil, ErrNoRecord
+		}
+
+		return 0, nil, err
+	}
+	t.Scopes = splitScopes(scopes)
+
+	// throttled like session activity to avoid a write on every request
+	stmt = `UPDATE api_tokens SET last_used = UTC_TIMESTAMP()
+	        WHERE id = ? AND (last_used IS NULL OR last_used < DATE_SUB(UTC_TIMESTAMP(), INTERVAL 1 MINUTE))`
+
+	if _, err = m.DB.Exec(stmt, t.ID); err != nil {
+		return 0, nil, err
+	}
+
+	return userID, &t, nil
+}
+
+// ForUser returns a user's tokens, newest first, including expired ones.
+func (m *APITokenModel) ForUser(userID int) ([]*APIToken, error) {
+	stmt := `SELECT id, name, scopes, created, expires, last_used FROM api_tokens
+	         WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*APIToken, 0)
+
+	for rows.Next() {
+		var t APIToken
+		var scopes string
+		err := rows.Scan(&t.ID, &t.Name, &scopes, &t.Created, &t.Expires, &t.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.Scopes = splitScopes(scopes)
+
+		tokens = append(tokens, &t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke deletes one of a user's tokens.
+func (m *APITokenModel) Revoke(userID, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// scopes are stored space separated, as in OAuth 2.0
+func joinScopes(scopes []APIScope) string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+
+	return strings.Join(s, " ")
+}
+
+func splitScopes(s string) []APIScope {
+	var scopes []APIScope
+	for _, f := range strings.Fields(s) {
+		scopes = append(scopes, APIScope(f))
+	}
+
+	return scopes
+}
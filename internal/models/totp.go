@@ -0,0 +1,235 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/pquerna/otp"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 5 // 10 hex characters per code
+)
+
+// TOTPModel manages the two-factor authentication state of users.
+// Secrets are sealed with AES-GCM using EncryptionKey before they are stored.
+type TOTPModel struct {
+	DB            *sql.DB
+	EncryptionKey []byte // 32 byte AES-256 key
+}
+
+// SetPending stores a new, not yet verified, TOTP key for a user.
+// Any previously enabled key and its recovery codes are discarded.
+func (m *TOTPModel) SetPending(userID int, key *otp.Key) error {
+	sealed, err := m.seal([]byte(key.String()))
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_key = ?, totp_enabled = FALSE WHERE id = ?`
+	if _, err = tx.Exec(stmt, sealed, userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Key returns the stored TOTP key for a user and whether it has been
+// verified and enabled.  ErrNoRecord is returned if no key is stored.
+func (m *TOTPModel) Key(userID int) (*otp.Key, bool, error) {
+	var sealed []byte
+	var enabled bool
+
+	stmt := `SELECT totp_key, totp_enabled FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&sealed, &enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, ErrNoRecord
+		}
+
+		return nil, false, err
+	}
+
+	if sealed == nil {
+		return nil, false, ErrNoRecord
+	}
+
+	raw, err := m.open(sealed)
+	if err != nil {
+		return nil, false, err
+	}
+
+	key, err := otp.NewKeyFromURL(string(raw))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return key, enabled, nil
+}
+
+// Enabled reports whether a user has verified two-factor authentication.
+func (m *TOTPModel) Enabled(userID int) (bool, error) {
+	var enabled bool
+
+	stmt := `SELECT totp_enabled FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&enabled)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, ErrNoRecord
+		}
+
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+// Enable marks the pending key of a user as verified and returns a fresh
+// set of single-use recovery codes.  Only hashes of the codes are stored.
+func (m *TOTPModel) Enable(userID int) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		codes[i] = hex.EncodeToString(b)
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_key IS NOT NULL`
+	result, err := tx.Exec(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrNoRecord
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return nil, err
+	}
+
+	stmt = `INSERT INTO recovery_codes (user_id, hashed_code) VALUES (?, ?)`
+	for _, code := range codes {
+		if _, err = tx.Exec(stmt, userID, hashRecoveryCode(code)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable removes the TOTP key and recovery codes of a user.
+func (m *TOTPModel) Disable(userID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE users SET totp_key = NULL, totp_enabled = FALSE WHERE id = ?`
+	if _, err = tx.Exec(stmt, userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode consumes an unused recovery code, returning false if the
+// code does not match any remaining code for the user.
+func (m *TOTPModel) UseRecoveryCode(userID int, code string) (bool, error) {
+	stmt := `UPDATE recovery_codes SET used = UTC_TIMESTAMP()
+	         WHERE user_id = ? AND hashed_code = ? AND used IS NULL`
+
+	result, err := m.DB.Exec(stmt, userID, hashRecoveryCode(code))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func hashRecoveryCode(code string) string {
+	return hashToken(strings.ToLower(strings.TrimSpace(code)))
+}
+
+// seal encrypts plaintext, prefixing the result with the random nonce.
+func (m *TOTPModel) seal(plaintext []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (m *TOTPModel) open(sealed []byte) ([]byte, error) {
+	gcm, err := m.gcm()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("models: sealed TOTP key is too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func (m *TOTPModel) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(m.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
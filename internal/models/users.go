@@ -1,7 +1,11 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"strings"
 	"time"
@@ -14,50 +18,125 @@ const (
 	mySQLErrDupEntry = 1062 // MySQL error number when UNIQUE constraint violated
 )
 
+// Role determines what a user is allowed to do beyond managing their own
+// snippets.  Each role includes the privileges of the roles before it.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// Roles lists every role from least to most privileged.
+var Roles = []Role{RoleUser, RoleModerator, RoleAdmin}
+
+// Includes reports whether r grants at least the privileges of other.
+func (r Role) Includes(other Role) bool {
+	return r.rank() >= other.rank()
+}
+
+// Valid reports whether r is a known role.
+func (r Role) Valid() bool {
+	return r.rank() >= 0
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if r == role {
+			return i
+		}
+	}
+
+	return -1
+}
+
 type User struct {
 	ID             int
 	Name           string
+	Handle         string // unique, used in public profile URLs
 	Email          string
+	EmailVerified  bool // the user has followed a link sent to Email
 	HashedPassword []byte
 	Created        time.Time
+	Role           Role
+	DisabledAt     sql.NullTime
+	DeletedAt      sql.NullTime // soft-deleted, awaiting purge
+}
+
+// Active reports whether the user may log in and use existing sessions.
+func (u *User) Active() bool {
+	return !u.DisabledAt.Valid && !u.DeletedAt.Valid
+}
+
+// UserModelInterface is implemented by UserModel and by the mock used in
+// tests.
+type UserModelInterface interface {
+	Insert(name, handle, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+	Get(id int) (*User, error)
+	GetMany(ids []int) ([]*User, error)
+	GetByHandle(handle string) (*User, error)
+	PasswordUpdate(id int, currentPassword, newPassword string) error
+	CheckPassword(id int, password string) error
+	NewEmailChange(id int, email string, ttl time.Duration) (string, error)
+	ConfirmEmailChange(token string) (int, error)
+	Search(query string, limit int) ([]*User, error)
+	RoleUpdate(id int, role Role) error
+	SetDisabled(id int, disabled bool) error
+	SoftDelete(id int) error
+	Restore(id int) error
+	PurgeDeleted(grace time.Duration) (int, error)
+	Stats() (*UserStats, error)
+	DeletePermanently(id int, anonymize bool) error
 }
 
 type UserModel struct {
 	DB *sql.DB
 }
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(name, handle, email, password string) error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
-	stmt := `INSERT INTO users (name, email, hashed_password, created)
-	         VALUES(?, ?, ?, UTC_TIMESTAMP())`
+	stmt := `INSERT INTO users (name, handle, email, hashed_password, created)
+	         VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
 
-	_, err = m.DB.Exec(stmt, name, email, hashedPassword)
-	if err != nil {
-		var mySQLError *mysql.MySQLError
-		if errors.As(err, &mySQLError) {
-			// check MySQL error code and that message contains index name
-			if mySQLError.Number == mySQLErrDupEntry &&
-				strings.Contains(mySQLError.Message, "user_uc_email") {
-				return ErrDuplicateEmail
-			}
+	_, err = m.DB.Exec(stmt, name, handle, email, hashedPassword)
+	return duplicateUserError(err)
+}
+
+// duplicateUserError maps unique constraint violations on users to
+// ErrDuplicateEmail or ErrDuplicateHandle.
+func duplicateUserError(err error) error {
+	var mySQLError *mysql.MySQLError
+	if errors.As(err, &mySQLError) && mySQLError.Number == mySQLErrDupEntry {
+		// check message contains index name
+		switch {
+		case strings.Contains(mySQLError.Message, "user_uc_email"):
+			return ErrDuplicateEmail
+		case strings.Contains(mySQLError.Message, "user_uc_handle"):
+			return ErrDuplicateHandle
 		}
 	}
 
-	return nil
+	return err
 }
 
 // Authenticate user credentials and return User ID if successful.
 func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
 	var hashedPassword []byte
+	var disabled bool
 
-	stmt := `SELECT id, hashed_password FROM users where email = ?`
+	// soft-deleted users are treated as though they don't exist
+	stmt := `SELECT id, hashed_password, disabled_at IS NOT NULL FROM users
+	         WHERE email = ? AND deleted_at IS NULL`
 
-	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword, &disabled)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// user record not found
@@ -77,9 +156,420 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 		return 0, err
 	}
 
+	// only reveal the account is disabled once the password is known
+	if disabled {
+		return 0, ErrAccountDisabled
+	}
+
 	return id, nil
 }
 
+// Exists reports whether a user with the given ID exists.
 func (m *UserModel) Exists(id int) (bool, error) {
-	return false, nil
+	var exists bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM users WHERE id = ?)`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}
+
+// Get returns the user with the given ID, excluding the password hash.
+func (m *UserModel) Get(id int) (*User, error) {
+	var user User
+
+	stmt := `SELECT id, name, handle, email, email_verified, created, role, disabled_at, deleted_at FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&user.ID, &user.Name, &user.Handle, &user.Email, &user.EmailVerified, &user.Created, &user.Role, &user.DisabledAt, &user.DeletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetMany returns the users with the given IDs in a single query, in no
+// particular order.  IDs that don't exist are skipped.
+func (m *UserModel) GetMany(ids []int) ([]*User, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	stmt := `SELECT id, name, handle, email, email_verified, created, role, disabled_at, deleted_at FROM users
+	         WHERE id IN (` + placeholders(len(args)) + `)`
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0, len(ids))
+
+	for rows.Next() {
+		var user User
+		err := rows.Scan(&user.ID, &user.Name, &user.Handle, &user.Email, &user.EmailVerified, &user.Created, &user.Role, &user.DisabledAt, &user.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetByHandle returns the active user with the given handle.  Disabled and
+// deleted users are reported as ErrNoRecord so their profiles disappear.
+func (m *UserModel) GetByHandle(handle string) (*User, error) {
+	var user User
+
+	stmt := `SELECT id, name, handle, email, email_verified, created, role, disabled_at, deleted_at FROM users
+	         WHERE handle = ? AND disabled_at IS NULL AND deleted_at IS NULL`
+
+	err := m.DB.QueryRow(stmt, handle).Scan(&user.ID, &user.Name, &user.Handle, &user.Email, &user.EmailVerified, &user.Created, &user.Role, &user.DisabledAt, &user.DeletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// PasswordUpdate replaces the password of a user after confirming their
+// current password, returning ErrInvalidCredentials if it does not match.
+func (m *UserModel) PasswordUpdate(id int, currentPassword, newPassword string) error {
+	err := m.CheckPassword(id, currentPassword)
+	if err != nil {
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE users SET hashed_password = ? WHERE id = ?`
+
+	_, err = m.DB.Exec(stmt, newHashedPassword, id)
+	return err
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the
+// current password of the user.
+func (m *UserModel) CheckPassword(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM users WHERE id = ?`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrInvalidCredentials
+		}
+
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// NewEmailChange records a request to change the email address of a user and
+// returns the token that must be presented to confirm the new address.  The
+// new address may be the current one, to verify it.
+func (m *UserModel) NewEmailChange(id int, email string, ttl time.Duration) (string, error) {
+	var taken bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM users WHERE email = ? AND id <> ?)`
+
+	err := m.DB.QueryRow(stmt, email, id).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+	if taken {
+		return "", ErrDuplicateEmail
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	// only the most recent request for a user is honored
+	_, err = m.DB.Exec(`DELETE FROM email_changes WHERE user_id = ?`, id)
+	if err != nil {
+		return "", err
+	}
+
+	stmt = `INSERT INTO email_changes (token_hash, user_id, email, expiry)
+	        VALUES (?, ?, ?, DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND))`
+
+	_, err = m.DB.Exec(stmt, hashToken(token), id, email, int(ttl.Seconds()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ConfirmEmailChange applies the email change identified by token, marking
+// the address as verified, and returns the ID of the affected user.
+// ErrNoRecord is returned for unknown or expired tokens.
+func (m *UserModel) ConfirmEmailChange(token string) (int, error) {
+	var id int
+	var email string
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT user_id, email FROM email_changes
+	         WHERE token_hash = ? AND expiry > UTC_TIMESTAMP()`
+
+	err = tx.QueryRow(stmt, hashToken(token)).Scan(&id, &email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrNoRecord
+		}
+
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET email = ?, email_verified = TRUE WHERE id = ?`, email, id)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == mySQLErrDupEntry &&
+				strings.Contains(mySQLError.Message, "user_uc_email") {
+				return 0, ErrDuplicateEmail
+			}
+		}
+
+		return 0, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM email_changes WHERE user_id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of a random token, which is
+// what gets stored so that a database leak doesn't reveal usable tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Search returns up to limit users whose name, handle or email contains query, most
+// recent signups first.  An empty query matches every user.
+func (m *UserModel) Search(query string, limit int) ([]*User, error) {
+	stmt := `SELECT id, name, handle, email, email_verified, created, role, disabled_at, deleted_at FROM users
+	         WHERE name LIKE ? OR handle LIKE ? OR email LIKE ? ORDER BY id DESC LIMIT ?`
+
+	pattern := "%" + escapeLike(query) + "%"
+
+	rows, err := m.DB.Query(stmt, pattern, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*User, 0)
+
+	for rows.Next() {
+		var u User
+		err := rows.Scan(&u.ID, &u.Name, &u.Handle, &u.Email, &u.EmailVerified, &u.Created, &u.Role, &u.DisabledAt, &u.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// RoleUpdate changes the role of a user.
+func (m *UserModel) RoleUpdate(id int, role Role) error {
+	stmt := `UPDATE users SET role = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, role, id)
+	return err
+}
+
+// SetDisabled disables or re-enables a user account.
+func (m *UserModel) SetDisabled(id int, disabled bool) error {
+	stmt := `UPDATE users SET disabled_at = IF(?, COALESCE(disabled_at, UTC_TIMESTAMP()), NULL)
+	         WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, disabled, id)
+	return err
+}
+
+// SoftDelete marks a user as deleted.  The account and its snippets are
+// retained until PurgeDeleted removes them, so it can still be restored.
+func (m *UserModel) SoftDelete(id int) error {
+	stmt := `UPDATE users SET deleted_at = COALESCE(deleted_at, UTC_TIMESTAMP()) WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// Restore undoes a soft delete that has not been purged yet.
+func (m *UserModel) Restore(id int) error {
+	stmt := `UPDATE users SET deleted_at = NULL WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// PurgeDeleted permanently removes users, and their snippets and sessions,
+// that were soft-deleted more than grace ago.  It returns the number of
+// users removed.
+func (m *UserModel) PurgeDeleted(grace time.Duration) (int, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	cutoff := `deleted_at < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+	seconds := int(grace.Seconds())
+
+	stmt := `DELETE s FROM snippets s JOIN users u ON u.id = s.user_id WHERE u.` + cutoff
+	if _, err = tx.Exec(stmt, seconds); err != nil {
+		return 0, err
+	}
+
+	stmt = `DELETE s FROM sessions s
+	        JOIN user_sessions us ON us.token = s.token
+	        JOIN users u ON u.id = us.user_id
+	        WHERE u.` + cutoff
+	if _, err = tx.Exec(stmt, seconds); err != nil {
+		return 0, err
+	}
+
+	// remaining dependent rows are removed by foreign key constraints
+	result, err := tx.Exec(`DELETE FROM users WHERE `+cutoff, seconds)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
+// UserStats summarizes the user accounts for the admin dashboard.
+type UserStats struct {
+	Total      int
+	Moderators int
+	Admins     int
+	Disabled   int
+	Deleted    int // soft-deleted, awaiting purge
+	LastWeek   int // signups in the last 7 days
+}
+
+func (m *UserModel) Stats() (*UserStats, error) {
+	var s UserStats
+
+	stmt := `SELECT COUNT(*),
+	                COALESCE(SUM(role = 'moderator'), 0),
+	                COALESCE(SUM(role = 'admin'), 0),
+	                COALESCE(SUM(disabled_at IS NOT NULL), 0),
+	                COALESCE(SUM(deleted_at IS NOT NULL), 0),
+	                COALESCE(SUM(created > DATE_SUB(UTC_TIMESTAMP(), INTERVAL 7 DAY)), 0)
+	         FROM users`
+
+	err := m.DB.QueryRow(stmt).Scan(&s.Total, &s.Moderators, &s.Admins, &s.Disabled, &s.Deleted, &s.LastWeek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// escapeLike escapes the wildcard characters of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// DeletePermanently removes a user immediately, together with their
+// sessions.  Their snippets are deleted, or kept without an owner when
+// anonymize is true.
+func (m *UserModel) DeletePermanently(id int, anonymize bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `DELETE FROM snippets WHERE user_id = ?`
+	if anonymize {
+		stmt = `UPDATE snippets SET user_id = NULL WHERE user_id = ?`
+	}
+
+	if _, err = tx.Exec(stmt, id); err != nil {
+		return err
+	}
+
+	stmt = `DELETE s FROM sessions s
+	        JOIN user_sessions us ON us.token = s.token
+	        WHERE us.user_id = ?`
+	if _, err = tx.Exec(stmt, id); err != nil {
+		return err
+	}
+
+	// remaining dependent rows are removed by foreign key constraints
+	if _, err = tx.Exec(`DELETE FROM users WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// WebAuthnCredential is a passkey registered by a user.
+type WebAuthnCredential struct {
+	ID         int
+	UserID     int
+	Name       string
+	Credential webauthn.Credential
+	Created    time.Time
+	LastUsed   sql.NullTime
+}
+
+// WebAuthnModelInterface is implemented by WebAuthnModel and by the mock
+// used in tests.
+type WebAuthnModelInterface interface {
+	Insert(userID int, name string, cred *webauthn.Credential) error
+	ForUser(userID int) ([]*WebAuthnCredential, error)
+	Touch(cred *webauthn.Credential) error
+	Delete(userID, id int) error
+}
+
+type WebAuthnModel struct {
+	DB *sql.DB
+}
+
+// Insert stores a newly registered credential for a user.
+func (m *WebAuthnModel) Insert(userID int, name string, cred *webauthn.Credential) error {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO webauthn_credentials (user_id, credential_id, name, data, created)
+	         VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, cred.ID, name, data)
+	if err != nil {
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == mySQLErrDupEntry &&
+				strings.Contains(mySQLError.Message, "webauthn_uc_credential") {
+				return ErrDuplicateCredential
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// ForUser returns all credentials registered by a user, oldest first.
+func (m *WebAuthnModel) ForUser(userID int) ([]*WebAuthnCredential, error) {
+	stmt := `SELECT id, user_id, name, data, created, last_used FROM webauthn_credentials
+	         WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	creds := make([]*WebAuthnCredential, 0)
+
+	for rows.Next() {
+		var c WebAuthnCredential
+		var data []byte
+
+		err := rows.Scan(&c.ID, &c.UserID, &c.Name, &data, &c.Created, &c.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, &c.Credential)
+		if err != nil {
+			return nil, err
+		}
+
+		creds = append(creds, &c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// Touch saves the updated authenticator state, such as the signature
+// counter, after a successful login with a credential.
+func (m *WebAuthnModel) Touch(cred *webauthn.Credential) error {
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE webauthn_credentials SET data = ?, last_used = UTC_TIMESTAMP()
+	         WHERE credential_id = ?`
+
+	_, err = m.DB.Exec(stmt, data, cred.ID)
+	return err
+}
+
+// Delete removes a credential, provided it belongs to the given user.
+func (m *WebAuthnModel) Delete(userID, id int) error {
+	stmt := `DELETE FROM webauthn_credentials WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,363 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// WebhookEvent names something that happened to a snippet.
+type WebhookEvent string
+
+const (
+	EventSnippetCreated WebhookEvent = "snippet.created"
+	EventSnippetUpdated WebhookEvent = "snippet.updated"
+	EventSnippetDeleted WebhookEvent = "snippet.deleted"
+	EventSnippetExpired WebhookEvent = "snippet.expired"
+
+	// EventPing is sent on request to test a webhook, whatever it is
+	// subscribed to.
+	EventPing WebhookEvent = "ping"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []WebhookEvent{EventSnippetCreated, EventSnippetUpdated, EventSnippetDeleted, EventSnippetExpired}
+
+func (e WebhookEvent) Valid() bool {
+	for _, event := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Webhook is a URL a user has asked to be sent snippet events.
+type Webhook struct {
+	ID      int
+	URL     string
+	Events  []WebhookEvent
+	Secret  string // hex encoded HMAC key
+	Created time.Time
+}
+
+// Delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is a queued event and the outcome of its latest attempt.
+type WebhookDelivery struct {
+	ID             int
+	WebhookID      int
+	URL            string
+	Secret         string
+	Event          WebhookEvent
+	Payload        []byte
+	Status         string
+	Attempts       int
+	ResponseStatus sql.NullInt64
+	Error          string
+	Created        time.Time
+	LastAttempt    sql.NullTime
+}
+
+// WebhookModelInterface is implemented by WebhookModel and by the mock used
+// in tests.
+type WebhookModelInterface interface {
+	Insert(userID int, url string, events []WebhookEvent) (int, error)
+	Get(userID, id int) (*Webhook, error)
+	ForUser(userID int) ([]*Webhook, error)
+	Delete(userID, id int) error
+	Enqueue(userID int, event WebhookEvent, payload []byte) error
+	EnqueuePing(userID, id int, payload []byte) error
+	Claim(limit int, lease time.Duration) ([]*WebhookDelivery, error)
+	RecordAttempt(id int, delivered bool, status int, errMsg string, retryIn time.Duration) error
+	Deliveries(userID, limit int) ([]*WebhookDelivery, error)
+	PurgeDeliveries(age time.Duration) (int, error)
+}
+
+type WebhookModel struct {
+	DB *sql.DB
+}
+
+// Insert registers a webhook with a new random signing secret.
+func (m *WebhookModel) Insert(userID int, url string, events []WebhookEvent) (int, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return 0, err
+	}
+
+	stmt := `INSERT INTO webhooks (user_id, url, events, secret, created)
+	         VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, userID, url, joinEvents(events), hex.EncodeToString(b))
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get returns one of a user's webhooks.
+func (m *WebhookModel) Get(userID, id int) (*Webhook, error) {
+	var h Webhook
+	var events string
+
+	stmt := `SELECT id, url, events, secret, created FROM webhooks WHERE id = ? AND user_id = ?`
+
+	err := m.DB.QueryRow(stmt, id, userID).Scan(&h.ID, &h.URL, &events, &h.Secret, &h.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+
+		return nil, err
+	}
+	h.Events = splitEvents(events)
+
+	return &h, nil
+}
+
+// ForUser returns a user's webhooks, oldest first.
+func (m *WebhookModel) ForUser(userID int) ([]*Webhook, error) {
+	stmt := `SELECT id, url, events, secret, created FROM webhooks WHERE user_id = ? ORDER BY id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := make([]*Webhook, 0)
+
+	for rows.Next() {
+		var h Webhook
+		var events string
+		err := rows.Scan(&h.ID, &h.URL, &events, &h.Secret, &h.Created)
+		if err != nil {
+			return nil, err
+		}
+		h.Events = splitEvents(events)
+
+		hooks = append(hooks, &h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+// Delete removes one of a user's webhooks along with its deliveries.
+func (m *WebhookModel) Delete(userID, id int) error {
+	stmt := `DELETE FROM webhooks WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Enqueue queues a delivery of payload to each of a user's webhooks
+// subscribed to event.  Nothing is queued for disabled or deleted users.
+func (m *WebhookModel) Enqueue(userID int, event WebhookEvent, payload []byte) error {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt, created)
+	         SELECT w.id, ?, ?, 'pending', 0, UTC_TIMESTAMP(), UTC_TIMESTAMP()
+	         FROM webhooks w JOIN users u ON u.id = w.user_id
+	         WHERE w.user_id = ? AND FIND_IN_SET(?, w.events)
+	         AND u.disabled_at IS NULL AND u.deleted_at IS NULL`
+
+	_, err := m.DB.Exec(stmt, event, payload, userID, event)
+	return err
+}
+
+// EnqueuePing queues a test delivery to one of a user's webhooks.
+func (m *WebhookModel) EnqueuePing(userID, id int, payload []byte) error {
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt, created)
+	         SELECT id, ?, ?, 'pending', 0, UTC_TIMESTAMP(), UTC_TIMESTAMP()
+	         FROM webhooks WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, EventPing, payload, id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Claim returns up to limit pending deliveries that are due, pushing their
+// next attempt back by lease so that concurrent workers skip them while
+// they are being sent.
+func (m *WebhookModel) Claim(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt := `SELECT d.id, d.webhook_id, w.url, w.secret, d.event, d.payload, d.status, d.attempts, d.created
+	         FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	         WHERE d.status = 'pending' AND d.next_attempt <= UTC_TIMESTAMP()
+	         ORDER BY d.next_attempt LIMIT ?
+	         FOR UPDATE OF d SKIP LOCKED`
+
+	rows, err := tx.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	ids := make([]any, 0)
+
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &d)
+		ids = append(ids, d.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return deliveries, nil
+	}
+
+	stmt = `UPDATE webhook_deliveries SET next_attempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	        WHERE id IN (` + placeholders(len(ids)) + `)`
+
+	_, err = tx.Exec(stmt, append([]any{int(lease.Seconds())}, ids...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, tx.Commit()
+}
+
+// RecordAttempt logs the outcome of sending a delivery.  Unless it was
+// delivered, it is retried after retryIn, or marked failed when retryIn is
+// zero.  status is the HTTP response status, or zero if there was none.
+func (m *WebhookModel) RecordAttempt(id int, delivered bool, status int, errMsg string, retryIn time.Duration) error {
+	next := DeliveryPending
+	switch {
+	case delivered:
+		next = DeliveryDelivered
+	case retryIn == 0:
+		next = DeliveryFailed
+	}
+
+	if len(errMsg) > 255 {
+		errMsg = errMsg[:255]
+	}
+
+	stmt := `UPDATE webhook_deliveries SET status = ?, attempts = attempts + 1,
+	         response_status = ?, error = ?, last_attempt = UTC_TIMESTAMP(),
+	         next_attempt = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	         WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, next, sql.NullInt64{Int64: int64(status), Valid: status != 0}, errMsg, int(retryIn.Seconds()), id)
+	return err
+}
+
+// Deliveries returns the most recent deliveries to a user's webhooks.
+func (m *WebhookModel) Deliveries(userID, limit int) ([]*WebhookDelivery, error) {
+	stmt := `SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.response_status, d.error, d.created, d.last_attempt
+	         FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+	         WHERE w.user_id = ? ORDER BY d.id DESC LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Event, &d.Status, &d.Attempts, &d.ResponseStatus, &d.Error, &d.Created, &d.LastAttempt)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// PurgeDeliveries removes finished deliveries older than age.
+func (m *WebhookModel) PurgeDeliveries(age time.Duration) (int, error) {
+	stmt := `DELETE FROM webhook_deliveries WHERE status <> 'pending'
+	         AND created < DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)`
+
+	result, err := m.DB.Exec(stmt, int(age.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// events are stored in a MySQL SET column, which reads back comma separated
+func joinEvents(events []WebhookEvent) string {
+	s := make([]string, len(events))
+	for i, e := range events {
+		s[i] = string(e)
+	}
+
+	return strings.Join(s, ",")
+}
+
+func splitEvents(s string) []WebhookEvent {
+	var events []WebhookEvent
+	for _, e := range strings.Split(s, ",") {
+		if e != "" {
+			events = append(events, WebhookEvent(e))
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,5 @@
+// Package snippetpb holds the protocol buffer messages and gRPC service
+// definitions generated from snippet.proto.
+package snippetpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative snippet.proto
@@ -0,0 +1,700 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        (unknown)
+// source: snippet.proto
+
+package snippetpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Visibility int32
+
+const (
+	Visibility_VISIBILITY_UNSPECIFIED Visibility = 0
+	Visibility_VISIBILITY_PUBLIC      Visibility = 1
+	Visibility_VISIBILITY_PRIVATE     Visibility = 2
+	Visibility_VISIBILITY_TEAM        Visibility = 3 // members of the snippet's team
+)
+
+// Enum value maps for Visibility.
+var (
+	Visibility_name = map[int32]string{
+		0: "VISIBILITY_UNSPECIFIED",
+		1: "VISIBILITY_PUBLIC",
+		2: "VISIBILITY_PRIVATE",
+		3: "VISIBILITY_TEAM",
+	}
+	Visibility_value = map[string]int32{
+		"VISIBILITY_UNSPECIFIED": 0,
+		"VISIBILITY_PUBLIC":      1,
+		"VISIBILITY_PRIVATE":     2,
+		"VISIBILITY_TEAM":        3,
+	}
+)
+
+func (x Visibility) Enum() *Visibility {
+	p := new(Visibility)
+	*p = x
+	return p
+}
+
+func (x Visibility) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Visibility) Descriptor() protoreflect.EnumDescriptor {
+	return file_snippet_proto_enumTypes[0].Descriptor()
+}
+
+func (Visibility) Type() protoreflect.EnumType {
+	return &file_snippet_proto_enumTypes[0]
+}
+
+func (x Visibility) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Visibility.Descriptor instead.
+func (Visibility) EnumDescriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{0}
+}
+
+type Snippet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	Created       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=created,proto3" json:"created,omitempty"`
+	Updated       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=updated,proto3" json:"updated,omitempty"` // unset if never edited
+	Expires       *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=expires,proto3" json:"expires,omitempty"`
+	Visibility    Visibility             `protobuf:"varint,7,opt,name=visibility,proto3,enum=snippetbox.v1.Visibility" json:"visibility,omitempty"`
+	TeamId        int64                  `protobuf:"varint,8,opt,name=team_id,json=teamId,proto3" json:"team_id,omitempty"` // zero unless visibility is team
+	Tags          []string               `protobuf:"bytes,9,rep,name=tags,proto3" json:"tags,omitempty"`
+	Hidden        bool                   `protobuf:"varint,10,opt,name=hidden,proto3" json:"hidden,omitempty"` // hidden by a moderator
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Snippet) Reset() {
+	*x = Snippet{}
+	mi := &file_snippet_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Snippet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Snippet) ProtoMessage() {}
+
+func (x *Snippet) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Snippet.ProtoReflect.Descriptor instead.
+func (*Snippet) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Snippet) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Snippet) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Snippet) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *Snippet) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Snippet) GetUpdated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *Snippet) GetExpires() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Expires
+	}
+	return nil
+}
+
+func (x *Snippet) GetVisibility() Visibility {
+	if x != nil {
+		return x.Visibility
+	}
+	return Visibility_VISIBILITY_UNSPECIFIED
+}
+
+func (x *Snippet) GetTeamId() int64 {
+	if x != nil {
+		return x.TeamId
+	}
+	return 0
+}
+
+func (x *Snippet) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Snippet) GetHidden() bool {
+	if x != nil {
+		return x.Hidden
+	}
+	return false
+}
+
+type CreateSnippetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ExpiresDays   int32                  `protobuf:"varint,3,opt,name=expires_days,json=expiresDays,proto3" json:"expires_days,omitempty"`          // 1, 7 or 365, defaults to 365
+	Visibility    Visibility             `protobuf:"varint,4,opt,name=visibility,proto3,enum=snippetbox.v1.Visibility" json:"visibility,omitempty"` // defaults to public
+	TeamId        int64                  `protobuf:"varint,5,opt,name=team_id,json=teamId,proto3" json:"team_id,omitempty"`                         // required for team visibility
+	Tags          []string               `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSnippetRequest) Reset() {
+	*x = CreateSnippetRequest{}
+	mi := &file_snippet_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSnippetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSnippetRequest) ProtoMessage() {}
+
+func (x *CreateSnippetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSnippetRequest.ProtoReflect.Descriptor instead.
+func (*CreateSnippetRequest) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateSnippetRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateSnippetRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *CreateSnippetRequest) GetExpiresDays() int32 {
+	if x != nil {
+		return x.ExpiresDays
+	}
+	return 0
+}
+
+func (x *CreateSnippetRequest) GetVisibility() Visibility {
+	if x != nil {
+		return x.Visibility
+	}
+	return Visibility_VISIBILITY_UNSPECIFIED
+}
+
+func (x *CreateSnippetRequest) GetTeamId() int64 {
+	if x != nil {
+		return x.TeamId
+	}
+	return 0
+}
+
+func (x *CreateSnippetRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type GetSnippetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSnippetRequest) Reset() {
+	*x = GetSnippetRequest{}
+	mi := &file_snippet_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSnippetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSnippetRequest) ProtoMessage() {}
+
+func (x *GetSnippetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSnippetRequest.ProtoReflect.Descriptor instead.
+func (*GetSnippetRequest) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetSnippetRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListSnippetsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Visibility    Visibility             `protobuf:"varint,2,opt,name=visibility,proto3,enum=snippetbox.v1.Visibility" json:"visibility,omitempty"`
+	Page          int32                  `protobuf:"varint,3,opt,name=page,proto3" json:"page,omitempty"` // starting from 1
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSnippetsRequest) Reset() {
+	*x = ListSnippetsRequest{}
+	mi := &file_snippet_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSnippetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSnippetsRequest) ProtoMessage() {}
+
+func (x *ListSnippetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSnippetsRequest.ProtoReflect.Descriptor instead.
+func (*ListSnippetsRequest) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListSnippetsRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ListSnippetsRequest) GetVisibility() Visibility {
+	if x != nil {
+		return x.Visibility
+	}
+	return Visibility_VISIBILITY_UNSPECIFIED
+}
+
+func (x *ListSnippetsRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+type ListSnippetsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Snippets      []*Snippet             `protobuf:"bytes,1,rep,name=snippets,proto3" json:"snippets,omitempty"`
+	NextPage      int32                  `protobuf:"varint,2,opt,name=next_page,json=nextPage,proto3" json:"next_page,omitempty"` // zero on the last page
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSnippetsResponse) Reset() {
+	*x = ListSnippetsResponse{}
+	mi := &file_snippet_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSnippetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSnippetsResponse) ProtoMessage() {}
+
+func (x *ListSnippetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSnippetsResponse.ProtoReflect.Descriptor instead.
+func (*ListSnippetsResponse) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListSnippetsResponse) GetSnippets() []*Snippet {
+	if x != nil {
+		return x.Snippets
+	}
+	return nil
+}
+
+func (x *ListSnippetsResponse) GetNextPage() int32 {
+	if x != nil {
+		return x.NextPage
+	}
+	return 0
+}
+
+type DeleteSnippetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSnippetRequest) Reset() {
+	*x = DeleteSnippetRequest{}
+	mi := &file_snippet_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSnippetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSnippetRequest) ProtoMessage() {}
+
+func (x *DeleteSnippetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSnippetRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSnippetRequest) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteSnippetRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type DeleteSnippetResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSnippetResponse) Reset() {
+	*x = DeleteSnippetResponse{}
+	mi := &file_snippet_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSnippetResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSnippetResponse) ProtoMessage() {}
+
+func (x *DeleteSnippetResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSnippetResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSnippetResponse) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{6}
+}
+
+type WatchSnippetsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Tag   string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"` // only snippets carrying tag
+	// resume after the snippet with this ID, rather than starting with
+	// snippets created after the call
+	AfterId       int64 `protobuf:"varint,2,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchSnippetsRequest) Reset() {
+	*x = WatchSnippetsRequest{}
+	mi := &file_snippet_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchSnippetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchSnippetsRequest) ProtoMessage() {}
+
+func (x *WatchSnippetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_snippet_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchSnippetsRequest.ProtoReflect.Descriptor instead.
+func (*WatchSnippetsRequest) Descriptor() ([]byte, []int) {
+	return file_snippet_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchSnippetsRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *WatchSnippetsRequest) GetAfterId() int64 {
+	if x != nil {
+		return x.AfterId
+	}
+	return 0
+}
+
+var File_snippet_proto protoreflect.FileDescriptor
+
+const file_snippet_proto_rawDesc = "" +
+	"\n" +
+	"\rsnippet.proto\x12\rsnippetbox.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xeb\x02\n" +
+	"\aSnippet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x124\n" +
+	"\acreated\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\acreated\x124\n" +
+	"\aupdated\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\aupdated\x124\n" +
+	"\aexpires\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\aexpires\x129\n" +
+	"\n" +
+	"visibility\x18\a \x01(\x0e2\x19.snippetbox.v1.VisibilityR\n" +
+	"visibility\x12\x17\n" +
+	"\ateam_id\x18\b \x01(\x03R\x06teamId\x12\x12\n" +
+	"\x04tags\x18\t \x03(\tR\x04tags\x12\x16\n" +
+	"\x06hidden\x18\n" +
+	" \x01(\bR\x06hidden\"\xd1\x01\n" +
+	"\x14CreateSnippetRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12!\n" +
+	"\fexpires_days\x18\x03 \x01(\x05R\vexpiresDays\x129\n" +
+	"\n" +
+	"visibility\x18\x04 \x01(\x0e2\x19.snippetbox.v1.VisibilityR\n" +
+	"visibility\x12\x17\n" +
+	"\ateam_id\x18\x05 \x01(\x03R\x06teamId\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\"#\n" +
+	"\x11GetSnippetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"v\n" +
+	"\x13ListSnippetsRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x129\n" +
+	"\n" +
+	"visibility\x18\x02 \x01(\x0e2\x19.snippetbox.v1.VisibilityR\n" +
+	"visibility\x12\x12\n" +
+	"\x04page\x18\x03 \x01(\x05R\x04page\"g\n" +
+	"\x14ListSnippetsResponse\x122\n" +
+	"\bsnippets\x18\x01 \x03(\v2\x16.snippetbox.v1.SnippetR\bsnippets\x12\x1b\n" +
+	"\tnext_page\x18\x02 \x01(\x05R\bnextPage\"&\n" +
+	"\x14DeleteSnippetRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x17\n" +
+	"\x15DeleteSnippetResponse\"C\n" +
+	"\x14WatchSnippetsRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x19\n" +
+	"\bafter_id\x18\x02 \x01(\x03R\aafterId*l\n" +
+	"\n" +
+	"Visibility\x12\x1a\n" +
+	"\x16VISIBILITY_UNSPECIFIED\x10\x00\x12\x15\n" +
+	"\x11VISIBILITY_PUBLIC\x10\x01\x12\x16\n" +
+	"\x12VISIBILITY_PRIVATE\x10\x02\x12\x13\n" +
+	"\x0fVISIBILITY_TEAM\x10\x032\xab\x03\n" +
+	"\x0eSnippetService\x12L\n" +
+	"\rCreateSnippet\x12#.snippetbox.v1.CreateSnippetRequest\x1a\x16.snippetbox.v1.Snippet\x12F\n" +
+	"\n" +
+	"GetSnippet\x12 .snippetbox.v1.GetSnippetRequest\x1a\x16.snippetbox.v1.Snippet\x12W\n" +
+	"\fListSnippets\x12\".snippetbox.v1.ListSnippetsRequest\x1a#.snippetbox.v1.ListSnippetsResponse\x12Z\n" +
+	"\rDeleteSnippet\x12#.snippetbox.v1.DeleteSnippetRequest\x1a$.snippetbox.v1.DeleteSnippetResponse\x12N\n" +
+	"\rWatchSnippets\x12#.snippetbox.v1.WatchSnippetsRequest\x1a\x16.snippetbox.v1.Snippet0\x01B+Z)snippetbox.mattman.net/internal/snippetpbb\x06proto3"
+
+var (
+	file_snippet_proto_rawDescOnce sync.Once
+	file_snippet_proto_rawDescData []byte
+)
+
+func file_snippet_proto_rawDescGZIP() []byte {
+	file_snippet_proto_rawDescOnce.Do(func() {
+		file_snippet_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_snippet_proto_rawDesc), len(file_snippet_proto_rawDesc)))
+	})
+	return file_snippet_proto_rawDescData
+}
+
+var file_snippet_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_snippet_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_snippet_proto_goTypes = []any{
+	(Visibility)(0),               // 0: snippetbox.v1.Visibility
+	(*Snippet)(nil),               // 1: snippetbox.v1.Snippet
+	(*CreateSnippetRequest)(nil),  // 2: snippetbox.v1.CreateSnippetRequest
+	(*GetSnippetRequest)(nil),     // 3: snippetbox.v1.GetSnippetRequest
+	(*ListSnippetsRequest)(nil),   // 4: snippetbox.v1.ListSnippetsRequest
+	(*ListSnippetsResponse)(nil),  // 5: snippetbox.v1.ListSnippetsResponse
+	(*DeleteSnippetRequest)(nil),  // 6: snippetbox.v1.DeleteSnippetRequest
+	(*DeleteSnippetResponse)(nil), // 7: snippetbox.v1.DeleteSnippetResponse
+	(*WatchSnippetsRequest)(nil),  // 8: snippetbox.v1.WatchSnippetsRequest
+	(*timestamppb.Timestamp)(nil), // 9: google.protobuf.Timestamp
+}
+var file_snippet_proto_depIdxs = []int32{
+	9,  // 0: snippetbox.v1.Snippet.created:type_name -> google.protobuf.Timestamp
+	9,  // 1: snippetbox.v1.Snippet.updated:type_name -> google.protobuf.Timestamp
+	9,  // 2: snippetbox.v1.Snippet.expires:type_name -> google.protobuf.Timestamp
+	0,  // 3: snippetbox.v1.Snippet.visibility:type_name -> snippetbox.v1.Visibility
+	0,  // 4: snippetbox.v1.CreateSnippetRequest.visibility:type_name -> snippetbox.v1.Visibility
+	0,  // 5: snippetbox.v1.ListSnippetsRequest.visibility:type_name -> snippetbox.v1.Visibility
+	1,  // 6: snippetbox.v1.ListSnippetsResponse.snippets:type_name -> snippetbox.v1.Snippet
+	2,  // 7: snippetbox.v1.SnippetService.CreateSnippet:input_type -> snippetbox.v1.CreateSnippetRequest
+	3,  // 8: snippetbox.v1.SnippetService.GetSnippet:input_type -> snippetbox.v1.GetSnippetRequest
+	4,  // 9: snippetbox.v1.SnippetService.ListSnippets:input_type -> snippetbox.v1.ListSnippetsRequest
+	6,  // 10: snippetbox.v1.SnippetService.DeleteSnippet:input_type -> snippetbox.v1.DeleteSnippetRequest
+	8,  // 11: snippetbox.v1.SnippetService.WatchSnippets:input_type -> snippetbox.v1.WatchSnippetsRequest
+	1,  // 12: snippetbox.v1.SnippetService.CreateSnippet:output_type -> snippetbox.v1.Snippet
+	1,  // 13: snippetbox.v1.SnippetService.GetSnippet:output_type -> snippetbox.v1.Snippet
+	5,  // 14: snippetbox.v1.SnippetService.ListSnippets:output_type -> snippetbox.v1.ListSnippetsResponse
+	7,  // 15: snippetbox.v1.SnippetService.DeleteSnippet:output_type -> snippetbox.v1.DeleteSnippetResponse
+	1,  // 16: snippetbox.v1.SnippetService.WatchSnippets:output_type -> snippetbox.v1.Snippet
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_snippet_proto_init() }
+func file_snippet_proto_init() {
+	if File_snippet_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_snippet_proto_rawDesc), len(file_snippet_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_snippet_proto_goTypes,
+		DependencyIndexes: file_snippet_proto_depIdxs,
+		EnumInfos:         file_snippet_proto_enumTypes,
+		MessageInfos:      file_snippet_proto_msgTypes,
+	}.Build()
+	File_snippet_proto = out.File
+	file_snippet_proto_goTypes = nil
+	file_snippet_proto_depIdxs = nil
+}
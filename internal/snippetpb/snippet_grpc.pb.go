@@ -0,0 +1,299 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: snippet.proto
+
+package snippetpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	SnippetService_CreateSnippet_FullMethodName = "/snippetbox.v1.SnippetService/CreateSnippet"
+	SnippetService_GetSnippet_FullMethodName    = "/snippetbox.v1.SnippetService/GetSnippet"
+	SnippetService_ListSnippets_FullMethodName  = "/snippetbox.v1.SnippetService/ListSnippets"
+	SnippetService_DeleteSnippet_FullMethodName = "/snippetbox.v1.SnippetService/DeleteSnippet"
+	SnippetService_WatchSnippets_FullMethodName = "/snippetbox.v1.SnippetService/WatchSnippets"
+)
+
+// SnippetServiceClient is the client API for SnippetService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// SnippetService is the gRPC API for internal services.  Calls are
+// authenticated with a personal API token sent as "authorization: Bearer
+// <token>" metadata, and follow the same rules as the JSON API: reads need
+// the snippets:read scope, creating and deleting snippets:write.
+type SnippetServiceClient interface {
+	// CreateSnippet creates a snippet owned by the caller.
+	CreateSnippet(ctx context.Context, in *CreateSnippetRequest, opts ...grpc.CallOption) (*Snippet, error)
+	// GetSnippet returns a snippet the caller may view.
+	GetSnippet(ctx context.Context, in *GetSnippetRequest, opts ...grpc.CallOption) (*Snippet, error)
+	// ListSnippets returns a page of the caller's own snippets, including
+	// expired, private and hidden ones.
+	ListSnippets(ctx context.Context, in *ListSnippetsRequest, opts ...grpc.CallOption) (*ListSnippetsResponse, error)
+	// DeleteSnippet permanently removes a snippet owned by the caller.
+	DeleteSnippet(ctx context.Context, in *DeleteSnippetRequest, opts ...grpc.CallOption) (*DeleteSnippetResponse, error)
+	// WatchSnippets streams public snippets as they are created.
+	WatchSnippets(ctx context.Context, in *WatchSnippetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Snippet], error)
+}
+
+type snippetServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSnippetServiceClient(cc grpc.ClientConnInterface) SnippetServiceClient {
+	return &snippetServiceClient{cc}
+}
+
+func (c *snippetServiceClient) CreateSnippet(ctx context.Context, in *CreateSnippetRequest, opts ...grpc.CallOption) (*Snippet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snippet)
+	err := c.cc.Invoke(ctx, SnippetService_CreateSnippet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snippetServiceClient) GetSnippet(ctx context.Context, in *GetSnippetRequest, opts ...grpc.CallOption) (*Snippet, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Snippet)
+	err := c.cc.Invoke(ctx, SnippetService_GetSnippet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snippetServiceClient) ListSnippets(ctx context.Context, in *ListSnippetsRequest, opts ...grpc.CallOption) (*ListSnippetsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSnippetsResponse)
+	err := c.cc.Invoke(ctx, SnippetService_ListSnippets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snippetServiceClient) DeleteSnippet(ctx context.Context, in *DeleteSnippetRequest, opts ...grpc.CallOption) (*DeleteSnippetResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteSnippetResponse)
+	err := c.cc.Invoke(ctx, SnippetService_DeleteSnippet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *snippetServiceClient) WatchSnippets(ctx context.Context, in *WatchSnippetsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Snippet], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &SnippetService_ServiceDesc.Streams[0], SnippetService_WatchSnippets_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchSnippetsRequest, Snippet]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SnippetService_WatchSnippetsClient = grpc.ServerStreamingClient[Snippet]
+
+// SnippetServiceServer is the server API for SnippetService service.
+// All implementations must embed UnimplementedSnippetServiceServer
+// for forward compatibility.
+//
+// SnippetService is the gRPC API for internal services.  Calls are
+// authenticated with a personal API token sent as "authorization: Bearer
+// <token>" metadata, and follow the same rules as the JSON API: reads need
+// the snippets:read scope, creating and deleting snippets:write.
+type SnippetServiceServer interface {
+	// CreateSnippet creates a snippet owned by the caller.
+	CreateSnippet(context.Context, *CreateSnippetRequest) (*Snippet, error)
+	// GetSnippet returns a snippet the caller may view.
+	GetSnippet(context.Context, *GetSnippetRequest) (*Snippet, error)
+	// ListSnippets returns a page of the caller's own snippets, including
+	// expired, private and hidden ones.
+	ListSnippets(context.Context, *ListSnippetsRequest) (*ListSnippetsResponse, error)
+	// DeleteSnippet permanently removes a snippet owned by the caller.
+	DeleteSnippet(context.Context, *DeleteSnippetRequest) (*DeleteSnippetResponse, error)
+	// WatchSnippets streams public snippets as they are created.
+	WatchSnippets(*WatchSnippetsRequest, grpc.ServerStreamingServer[Snippet]) error
+	mustEmbedUnimplementedSnippetServiceServer()
+}
+
+// UnimplementedSnippetServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSnippetServiceServer struct{}
+
+func (UnimplementedSnippetServiceServer) CreateSnippet(context.Context, *CreateSnippetRequest) (*Snippet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSnippet not implemented")
+}
+func (UnimplementedSnippetServiceServer) GetSnippet(context.Context, *GetSnippetRequest) (*Snippet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSnippet not implemented")
+}
+func (UnimplementedSnippetServiceServer) ListSnippets(context.Context, *ListSnippetsRequest) (*ListSnippetsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSnippets not implemented")
+}
+func (UnimplementedSnippetServiceServer) DeleteSnippet(context.Context, *DeleteSnippetRequest) (*DeleteSnippetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSnippet not implemented")
+}
+func (UnimplementedSnippetServiceServer) WatchSnippets(*WatchSnippetsRequest, grpc.ServerStreamingServer[Snippet]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSnippets not implemented")
+}
+func (UnimplementedSnippetServiceServer) mustEmbedUnimplementedSnippetServiceServer() {}
+func (UnimplementedSnippetServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeSnippetServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SnippetServiceServer will
+// result in compilation errors.
+type UnsafeSnippetServiceServer interface {
+	mustEmbedUnimplementedSnippetServiceServer()
+}
+
+func RegisterSnippetServiceServer(s grpc.ServiceRegistrar, srv SnippetServiceServer) {
+	// If the following call pancis, it indicates UnimplementedSnippetServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&SnippetService_ServiceDesc, srv)
+}
+
+func _SnippetService_CreateSnippet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnippetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnippetServiceServer).CreateSnippet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnippetService_CreateSnippet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnippetServiceServer).CreateSnippet(ctx, req.(*CreateSnippetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnippetService_GetSnippet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSnippetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnippetServiceServer).GetSnippet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnippetService_GetSnippet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnippetServiceServer).GetSnippet(ctx, req.(*GetSnippetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnippetService_ListSnippets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnippetsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnippetServiceServer).ListSnippets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnippetService_ListSnippets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnippetServiceServer).ListSnippets(ctx, req.(*ListSnippetsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnippetService_DeleteSnippet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSnippetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SnippetServiceServer).DeleteSnippet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: SnippetService_DeleteSnippet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SnippetServiceServer).DeleteSnippet(ctx, req.(*DeleteSnippetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _SnippetService_WatchSnippets_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchSnippetsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SnippetServiceServer).WatchSnippets(m, &grpc.GenericServerStream[WatchSnippetsRequest, Snippet]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type SnippetService_WatchSnippetsServer = grpc.ServerStreamingServer[Snippet]
+
+// SnippetService_ServiceDesc is the grpc.ServiceDesc for SnippetService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var SnippetService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "snippetbox.v1.SnippetService",
+	HandlerType: (*SnippetServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateSnippet",
+			Handler:    _SnippetService_CreateSnippet_Handler,
+		},
+		{
+			MethodName: "GetSnippet",
+			Handler:    _SnippetService_GetSnippet_Handler,
+		},
+		{
+			MethodName: "ListSnippets",
+			Handler:    _SnippetService_ListSnippets_Handler,
+		},
+		{
+			MethodName: "DeleteSnippet",
+			Handler:    _SnippetService_DeleteSnippet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchSnippets",
+			Handler:       _SnippetService_WatchSnippets_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "snippet.proto",
+}
@@ -8,6 +8,14 @@ import (
 
 var EmailRegex = regexp.MustCompile("[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// HandleRegex matches public profile handles: 3 to 30 lowercase letters,
+// digits and underscores.
+var HandleRegex = regexp.MustCompile("^[a-z0-9_]{3,30}$")
+
+// TagRegex matches snippet tags: up to 30 lowercase letters, digits, hyphens
+// and underscores, starting with a letter or digit.
+var TagRegex = regexp.MustCompile("^[a-z0-9][a-z0-9_-]{0,29}$")
+
 type Validator struct {
 	NonFieldErrors []string
 	FieldErrors    map[string]string
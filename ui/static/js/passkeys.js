@@ -0,0 +1,119 @@
+// WebAuthn passkey registration and login ceremonies.
+// Binary values are exchanged with the server as unpadded base64url strings.
+
+function bufferFromBase64url(value) {
+	var base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+	while (base64.length % 4) {
+		base64 += "=";
+	}
+	var binary = atob(base64);
+	var bytes = new Uint8Array(binary.length);
+	for (var i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes.buffer;
+}
+
+function base64urlFromBuffer(buffer) {
+	var bytes = new Uint8Array(buffer);
+	var binary = "";
+	for (var i = 0; i < bytes.length; i++) {
+		binary += String.fromCharCode(bytes[i]);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function postJSON(url, body) {
+	return fetch(url, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {"Content-Type": "application/json"},
+		body: body ? JSON.stringify(body) : null
+	}).then(function (resp) {
+		if (!resp.ok) {
+			throw new Error(resp.statusText);
+		}
+		return resp.json();
+	});
+}
+
+function showPasskeyError(err) {
+	var el = document.getElementById("passkey-error");
+	if (el) {
+		el.textContent = "Passkey request failed: " + err.message;
+		el.hidden = false;
+	}
+}
+
+function registerPasskey(event) {
+	event.preventDefault();
+	var name = document.getElementById("passkey-name").value.trim();
+	if (!name) {
+		showPasskeyError(new Error("a name is required"));
+		return;
+	}
+
+	postJSON("/account/passkeys/register/begin").then(function (options) {
+		var pk = options.publicKey;
+		pk.challenge = bufferFromBase64url(pk.challenge);
+		pk.user.id = bufferFromBase64url(pk.user.id);
+		(pk.excludeCredentials || []).forEach(function (c) {
+			c.id = bufferFromBase64url(c.id);
+		});
+		return navigator.credentials.create({publicKey: pk});
+	}).then(function (cred) {
+		return postJSON("/account/passkeys/register/finish?name=" + encodeURIComponent(name), {
+			id: cred.id,
+			rawId: base64urlFromBuffer(cred.rawId),
+			type: cred.type,
+			response: {
+				clientDataJSON: base64urlFromBuffer(cred.response.clientDataJSON),
+				attestationObject: base64urlFromBuffer(cred.response.attestationObject),
+				transports: cred.response.getTransports ? cred.response.getTransports() : []
+			}
+		});
+	}).then(function (result) {
+		window.location = result.redirect;
+	}).catch(showPasskeyError);
+}
+
+function loginWithPasskey(event) {
+	event.preventDefault();
+
+	postJSON("/user/login/passkey/begin").then(function (options) {
+		var pk = options.publicKey;
+		pk.challenge = bufferFromBase64url(pk.challenge);
+		(pk.allowCredentials || []).forEach(function (c) {
+			c.id = bufferFromBase64url(c.id);
+		});
+		return navigator.credentials.get({publicKey: pk});
+	}).then(function (cred) {
+		return postJSON("/user/login/passkey/finish", {
+			id: cred.id,
+			rawId: base64urlFromBuffer(cred.rawId),
+			type: cred.type,
+			response: {
+				clientDataJSON: base64urlFromBuffer(cred.response.clientDataJSON),
+				authenticatorData: base64urlFromBuffer(cred.response.authenticatorData),
+				signature: base64urlFromBuffer(cred.response.signature),
+				userHandle: cred.response.userHandle ? base64urlFromBuffer(cred.response.userHandle) : null
+			}
+		});
+	}).then(function (result) {
+		window.location = result.redirect;
+	}).catch(showPasskeyError);
+}
+
+var registerForm = document.getElementById("passkey-register");
+if (registerForm) {
+	registerForm.addEventListener("submit", registerPasskey);
+}
+
+var loginButton = document.getElementById("passkey-login");
+if (loginButton) {
+	if (window.PublicKeyCredential) {
+		loginButton.addEventListener("click", loginWithPasskey);
+	} else {
+		loginButton.hidden = true;
+	}
+}